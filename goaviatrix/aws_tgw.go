@@ -7,8 +7,14 @@ import (
 	"log"
 	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// routeDomainFetchConcurrency bounds the number of view_route_domain_details
+// calls that GetAWSTgw and GetAWSTgwDetails keep in flight at once.
+const routeDomainFetchConcurrency = 8
+
 // AwsTGW simple struct to hold aws_tgw details
 type AWSTgw struct {
 	Action                    string               `form:"action,omitempty"`
@@ -19,6 +25,12 @@ type AWSTgw struct {
 	AwsSideAsNumber           string               `form:"aws_side_asn,omitempty"`
 	AttachedAviatrixTransitGW []string             `form:"attached_aviatrix_transit_gateway,omitempty"`
 	SecurityDomains           []SecurityDomainRule `form:"security_domains,omitempty"`
+
+	// ManageDomainFlags makes UpdateAWSTgw create new domains with the
+	// firewall and egress flags of their rules and fail when those of an
+	// existing domain differ, since the controller cannot change them. Without
+	// it new domains get no flags and existing flags are left as they are.
+	ManageDomainFlags bool `form:"-"`
 }
 
 type AWSTgwAPIResp struct {
@@ -46,6 +58,9 @@ type RouteDomainDetail struct {
 	AttachedVPC          []AttachedVPCDetail  `json:"attached_vpc"`
 	RoutesInRouteTable   []RoutesInRouteTable `json:"routes_in_route_table"`
 	RouteTableId         string               `json:"route_table_id"`
+	FirewallDomain       bool                 `json:"firewall_domain"`
+	NativeEgressDomain   bool                 `json:"native_egress_domain"`
+	NativeFirewallDomain bool                 `json:"native_firewall_domain"`
 }
 
 type AttachedVPCDetail struct {
@@ -103,11 +118,46 @@ func (c *Client) CreateAWSTgw(awsTgw *AWSTgw) error {
 
 func (c *Client) GetAWSTgw(awsTgw *AWSTgw) (*AWSTgw, error) {
 	awsTgw.CID = c.CID
-	path := c.baseURL + fmt.Sprintf("?action=list_route_domain_names&tgw_name=%s&CID=%s", awsTgw.Name,
-		awsTgw.CID)
+	connectedDomainList, err := c.listRouteDomainNames(awsTgw.Name)
+	if err != nil {
+		return nil, err
+	}
+	routeDomainDetails, err := c.getRouteDomainDetails(awsTgw.Name, connectedDomainList)
+	if err != nil {
+		return nil, err
+	}
 
-	resp, err := c.Get(path, nil)
+	var transitGws map[string]string
+	for _, routeDomainDetail := range routeDomainDetails {
+		sdr := newSecurityDomainRule(routeDomainDetail)
+		if routeDomainDetail.Name == "Aviatrix_Edge_Domain" {
+			for _, attachedVPC := range routeDomainDetail.AttachedVPC {
+				if transitGws == nil {
+					if transitGws, err = c.listTransitGwNames(); err != nil {
+						return nil, err
+					}
+				}
+				gwName, ok := transitGws[attachedVPC.VPCId]
+				if !ok {
+					log.Printf("Couldn't find transit gateway attached to vpc %s", attachedVPC.VPCId)
+					return nil, ErrNotFound
+				}
+				awsTgw.AttachedAviatrixTransitGW = append(awsTgw.AttachedAviatrixTransitGW, gwName)
+			}
+		}
+
+		awsTgw.SecurityDomains = append(awsTgw.SecurityDomains, sdr)
+	}
 
+	return awsTgw, nil
+}
+
+// listRouteDomainNames returns the security domains of a transit gateway,
+// starting with Aviatrix_Edge_Domain, which list_route_domain_names leaves
+// out.
+func (c *Client) listRouteDomainNames(tgwName string) ([]string, error) {
+	path := c.baseURL + fmt.Sprintf("?action=list_route_domain_names&tgw_name=%s&CID=%s", tgwName, c.CID)
+	resp, err := c.Get(path, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -123,66 +173,64 @@ func (c *Client) GetAWSTgw(awsTgw *AWSTgw) (*AWSTgw, error) {
 	if !data.Return {
 		return nil, errors.New(data.Reason)
 	}
+	return append([]string{"Aviatrix_Edge_Domain"}, data.Results...), nil
+}
 
-	connectedDomainList := data.Results
-	connectedDomainList = append([]string{"Aviatrix_Edge_Domain"}, connectedDomainList...)
-
-	for i := range connectedDomainList {
-		dm := connectedDomainList[i]
-
-		path = c.baseURL + fmt.Sprintf("?action=view_route_domain_details&CID=%s&tgw_name=%s"+
-			"&route_domain_name=%s", c.CID, awsTgw.Name, dm)
-		resp, err = c.Get(path, nil)
-		if err != nil {
-			return nil, err
+// getRouteDomainDetails fetches the details of the named security domains,
+// keeping up to routeDomainFetchConcurrency requests in flight, and returns
+// them in the order of names. No new requests are started once one fails.
+func (c *Client) getRouteDomainDetails(tgwName string, names []string) ([]*RouteDomainDetail, error) {
+	details := make([]*RouteDomainDetail, len(names))
+	errs := make([]error, len(names))
+
+	var wg sync.WaitGroup
+	var failed int32
+	sem := make(chan struct{}, routeDomainFetchConcurrency)
+	for i := range names {
+		sem <- struct{}{}
+		if atomic.LoadInt32(&failed) != 0 {
+			<-sem
+			break
 		}
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			details[i], errs[i] = c.getRouteDomainDetail(tgwName, names[i])
+			if errs[i] != nil {
+				atomic.StoreInt32(&failed, 1)
+			}
+		}(i)
+	}
+	wg.Wait()
 
-		var data1 RouteDomainAPIResp
-		if err = json.NewDecoder(resp.Body).Decode(&data1); err != nil {
+	for _, err := range errs {
+		if err != nil {
 			return nil, err
 		}
-		if !data1.Return {
-			return nil, errors.New(data1.Reason)
-		}
-		routeDomainDetail := data1.Results
-
-		sdr := SecurityDomainRule{
-			Name: routeDomainDetail[0].Name,
-		}
-		for i := range routeDomainDetail[0].ConnectedRouteDomain {
-			sdr.ConnectedDomain = append(sdr.ConnectedDomain, routeDomainDetail[0].ConnectedRouteDomain[i])
-		}
-
-		attachedVPCs := routeDomainDetail[0].AttachedVPC
-		for i := range attachedVPCs {
-
-			if dm != "Aviatrix_Edge_Domain" {
-				vpcSolo := VPCSolo{
-					Region:      attachedVPCs[i].Region,
-					AccountName: attachedVPCs[i].AccountName,
-					VpcID:       attachedVPCs[i].VPCId,
-				}
-				sdr.AttachedVPCs = append(sdr.AttachedVPCs, vpcSolo)
-			} else {
-				gateway := &Gateway{
-					VpcID: attachedVPCs[i].VPCId,
-				}
-				gateway, err = c.GetTransitGwFromVpcID(gateway)
-				if err != nil {
-					return nil, err
-				}
-				awsTgw.AttachedAviatrixTransitGW = append(awsTgw.AttachedAviatrixTransitGW, gateway.GwName)
-			}
-		}
-
-		awsTgw.SecurityDomains = append(awsTgw.SecurityDomains, sdr)
 	}
-
-	return awsTgw, nil
+	return details, nil
 }
 
-func (c *Client) UpdateAWSTgw(awsTgw *AWSTgw) error {
-	return nil
+func (c *Client) getRouteDomainDetail(tgwName string, domainName string) (*RouteDomainDetail, error) {
+	path := c.baseURL + fmt.Sprintf("?action=view_route_domain_details&CID=%s&tgw_name=%s"+
+		"&route_domain_name=%s", c.CID, tgwName, domainName)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var data RouteDomainAPIResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		return nil, errors.New(data.Reason)
+	}
+	if len(data.Results) == 0 {
+		return nil, fmt.Errorf("no details returned for security domain %s", domainName)
+	}
+	return &data.Results[0], nil
 }
 
 func (c *Client) DeleteAWSTgw(awsTgw *AWSTgw) error {
@@ -204,110 +252,107 @@ func (c *Client) DeleteAWSTgw(awsTgw *AWSTgw) error {
 	return nil
 }
 
+// ValidateAWSTgwDomains checks domains, connections given as domain name
+// pairs and VPC attachments given as [domain, VPC ID] rows with
+// ValidateSecurityDomains, and returns the domains to create, the connections
+// to make and the connections between default domains to remove. Rows that
+// are too short are reported rather than indexed. domainsAll is sorted in
+// place.
 func (c *Client) ValidateAWSTgwDomains(domainsAll []string, domainConnAll [][]string, attachedVPCAll [][]string,
 ) ([]string, [][]string, [][]string, error) {
 
 	sort.Strings(domainsAll)
 
-	numOfDomains := len(domainsAll)
-	matrix := make([][]int, numOfDomains)
-	var domainsToCreate []string
-	var domainConnPolicy [][]string
-	var domainConnRemove [][]string
-
-	for i := range matrix {
-		matrix[i] = make([]int, numOfDomains)
-	}
-
-	m := make(map[string]int)
-	for i := 1; i <= numOfDomains; i++ {
-		if m[domainsAll[i-1]] != 0 {
-			err := fmt.Errorf("duplicate domains (name: %v) to create", domainsAll[i-1])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
+	var errs SecurityDomainErrors
+	rules := make([]SecurityDomainRule, len(domainsAll))
+	index := make(map[string]int)
+	for i, name := range domainsAll {
+		rules[i].Name = name
+		if _, ok := index[name]; !ok {
+			index[name] = i
 		}
-		m[domainsAll[i-1]] = i
 	}
-
-	m1 := make(map[string]int)
-	for i := 1; i <= len(attachedVPCAll); i++ {
-		if m1[attachedVPCAll[i-1][1]] != 0 {
-			err := fmt.Errorf("duplicate VPC IDs (ID: %v) to attach", attachedVPCAll[i-1][1])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
+	// connRows[j][k] is the domain_connections row of rules[j].ConnectedDomain[k]
+	connRows := make([][]int, len(rules))
+	for i, conn := range domainConnAll {
+		path := fmt.Sprintf("domain_connections[%d]", i)
+		if len(conn) < 2 {
+			errs = append(errs, &SecurityDomainError{Path: path, Value: strings.Join(conn, " - "),
+				Err: errors.New("want a pair of domain names")})
+			continue
 		}
-		m1[attachedVPCAll[i-1][1]] = i
-	}
-
-	var dmConnections []string
-
-	for i := range domainConnAll {
-		x := m[domainConnAll[i][0]]
-		y := m[domainConnAll[i][1]]
-
-		temp := "" + domainConnAll[i][0] + " - " + domainConnAll[i][1]
-		dmConnections = append(dmConnections, temp)
-
-		if x == 0 {
-			err := fmt.Errorf("unrecognized domain name (%v) in domain connection", domainConnAll[i][0])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
+		j, ok := index[conn[0]]
+		if !ok {
+			errs = append(errs, &SecurityDomainError{Path: path, Value: conn[0],
+				Err: errors.New("unrecognized domain name")})
+			continue
 		}
-		if y == 0 {
-			err := fmt.Errorf("unrecognized domain name (%v) in domain connection", domainConnAll[i][1])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
+		rules[j].ConnectedDomain = append(rules[j].ConnectedDomain, conn[1])
+		connRows[j] = append(connRows[j], i)
+	}
+	vpcs := make(map[string]int)
+	for i, vpc := range attachedVPCAll {
+		path := fmt.Sprintf("attached_vpcs[%d]", i)
+		if len(vpc) < 2 {
+			errs = append(errs, &SecurityDomainError{Path: path, Value: strings.Join(vpc, ", "),
+				Err: errors.New("want a domain name and a VPC ID")})
+			continue
 		}
-		if x == y {
-			err := fmt.Errorf("connection between same domains (name: %v)", domainConnAll[i][0])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
+		if j, ok := vpcs[vpc[1]]; ok {
+			errs = append(errs, &SecurityDomainError{Path: path, Value: vpc[1],
+				Err: fmt.Errorf("duplicate VPC ID, first attached at attached_vpcs[%d]", j)})
+			continue
 		}
-
-		matrix[x-1][y-1] = 1
+		vpcs[vpc[1]] = i
 	}
-
-	m2 := make(map[string]int)
-	for i := 1; i <= len(dmConnections); i++ {
-		if m2[dmConnections[i-1]] != 0 {
-			err := fmt.Errorf("duplicate domain connections (%v)", dmConnections[i-1])
-			return domainsToCreate, domainConnPolicy, domainConnRemove, err
-		}
-		m2[dmConnections[i-1]] = i
+	err := validateSecurityDomains(rules, true, func(j, k int) string {
+		return fmt.Sprintf("domain_connections[%d]", connRows[j][k])
+	})
+	if err != nil {
+		errs = append(errs, err.(SecurityDomainErrors)...)
 	}
-
-	for i := 0; i < numOfDomains; i++ {
-		for j := i + 1; j < numOfDomains; j++ {
-			if matrix[i][j] != matrix[j][i] {
-				err := fmt.Errorf("unsymmetric domain connection (%v)", ""+domainsAll[i]+" - "+domainsAll[j])
-				return domainsToCreate, domainConnPolicy, domainConnRemove, err
-			}
-		}
+	if len(errs) != 0 {
+		return nil, nil, nil, errs
 	}
 
+	conns := domainConnectionSet(rules)
 	defaultX := [3]string{"Default_Domain", "Shared_Service_Domain", "Aviatrix_Edge_Domain"}
+	isDefault := make(map[string]bool)
+	for _, name := range defaultX {
+		isDefault[name] = true
+	}
+
+	var domainsToCreate []string
+	var domainConnPolicy [][]string
+	var domainConnRemove [][]string
 
 	for i := 0; i < 3; i++ {
-		for j := i; j < 3; j++ {
-			if i != j {
-				if matrix[m[defaultX[i]]-1][m[defaultX[j]]-1] == 0 {
-					temp := []string{defaultX[i], defaultX[j]}
-					domainConnRemove = append(domainConnRemove, temp)
-				}
-				matrix[m[defaultX[i]]-1][m[defaultX[j]]-1] = 2
-				matrix[m[defaultX[j]]-1][m[defaultX[i]]-1] = 2
+		for j := i + 1; j < 3; j++ {
+			pair := [2]string{defaultX[i], defaultX[j]}
+			if pair[1] < pair[0] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if !conns[pair] {
+				domainConnRemove = append(domainConnRemove, []string{defaultX[i], defaultX[j]})
 			}
 		}
 	}
 
-	for i := range domainConnAll {
-		if matrix[m[domainConnAll[i][0]]-1][m[domainConnAll[i][1]]-1] == 1 {
-			matrix[m[domainConnAll[i][0]]-1][m[domainConnAll[i][1]]-1] = 2
-			matrix[m[domainConnAll[i][1]]-1][m[domainConnAll[i][0]]-1] = 2
-			temp := []string{domainConnAll[i][0], domainConnAll[i][1]}
-			domainConnPolicy = append(domainConnPolicy, temp)
+	added := make(map[[2]string]bool)
+	for _, conn := range domainConnAll {
+		pair := [2]string{conn[0], conn[1]}
+		if pair[1] < pair[0] {
+			pair[0], pair[1] = pair[1], pair[0]
+		}
+		if added[pair] || (isDefault[conn[0]] && isDefault[conn[1]]) {
+			continue
 		}
+		added[pair] = true
+		domainConnPolicy = append(domainConnPolicy, []string{conn[0], conn[1]})
 	}
 
 	for i := range domainsAll {
-		if domainsAll[i] != "Default_Domain" &&
-			domainsAll[i] != "Shared_Service_Domain" &&
-			domainsAll[i] != "Aviatrix_Edge_Domain" {
+		if !isDefault[domainsAll[i]] {
 			domainsToCreate = append(domainsToCreate, domainsAll[i])
 		}
 	}
@@ -409,6 +454,22 @@ func (c *Client) DetachVpcFromAWSTgw(awsTgw *AWSTgw, vpcID string) error {
 }
 
 func (c *Client) GetTransitGwFromVpcID(gateway *Gateway) (*Gateway, error) {
+	transitGws, err := c.listTransitGwNames()
+	if err != nil {
+		return nil, err
+	}
+	gwName, ok := transitGws[gateway.VpcID]
+	if !ok {
+		log.Printf("Couldn't find transit gateway attached to vpc %s", gateway.VpcID)
+		return nil, ErrNotFound
+	}
+	gateway.GwName = gwName
+	return gateway, nil
+}
+
+// listTransitGwNames returns the names of the transit gateways known to the
+// controller by VPC ID, from a single list_vpcs_summary call.
+func (c *Client) listTransitGwNames() (map[string]string, error) {
 	path := c.baseURL + fmt.Sprintf("?action=list_vpcs_summary&CID=%s", c.CID)
 	resp, err := c.Get(path, nil)
 
@@ -429,6 +490,7 @@ func (c *Client) GetTransitGwFromVpcID(gateway *Gateway) (*Gateway, error) {
 		return nil, errors.New(data.Reason)
 	}
 
+	transitGws := make(map[string]string)
 	vpcLists := data.Results
 	for i := range vpcLists {
 		vpcId := vpcLists[i].VPCId
@@ -437,12 +499,10 @@ func (c *Client) GetTransitGwFromVpcID(gateway *Gateway) (*Gateway, error) {
 			if index > 0 {
 				vpcId = vpcId[:index]
 			}
-			if vpcId == gateway.VpcID {
-				gateway.GwName = vpcLists[i].Name
-				return gateway, nil
+			if _, ok := transitGws[vpcId]; !ok {
+				transitGws[vpcId] = vpcLists[i].Name
 			}
 		}
 	}
-	log.Printf("Couldn't find transit gateway attached to vpc %s", gateway.VpcID)
-	return nil, ErrNotFound
+	return transitGws, nil
 }
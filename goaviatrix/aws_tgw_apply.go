@@ -0,0 +1,330 @@
+package goaviatrix
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// defaultSecurityDomains are created with every transit gateway and are never
+// created or deleted by UpdateAWSTgw.
+var defaultSecurityDomains = []string{"Aviatrix_Edge_Domain", "Default_Domain", "Shared_Service_Domain"}
+
+// TgwVPCChange is a VPC attached to or detached from a security domain.
+type TgwVPCChange struct {
+	Domain string
+	VPC    VPCSolo
+}
+
+// AWSTgwPlan lists the changes UpdateAWSTgw makes to bring a transit gateway
+// to the desired state. Connections are domain name pairs sorted by name. A
+// VPC that moves between domains is detached from the old one and attached to
+// the new one.
+type AWSTgwPlan struct {
+	Tgw                AWSTgw
+	DomainsCreated     []string
+	DomainsDeleted     []string
+	ConnectionsAdded   [][2]string
+	ConnectionsRemoved [][2]string
+	VPCsAttached       []TgwVPCChange
+	VPCsDetached       []TgwVPCChange
+	TransitGwsAttached []string
+	TransitGwsDetached []string
+}
+
+// HasChanges reports whether the plan contains any operation.
+func (p *AWSTgwPlan) HasChanges() bool {
+	return len(p.DomainsCreated) != 0 || len(p.DomainsDeleted) != 0 ||
+		len(p.ConnectionsAdded) != 0 || len(p.ConnectionsRemoved) != 0 ||
+		len(p.VPCsAttached) != 0 || len(p.VPCsDetached) != 0 ||
+		len(p.TransitGwsAttached) != 0 || len(p.TransitGwsDetached) != 0
+}
+
+// String renders the plan one operation per line in the order
+// ApplyAWSTgwPlan performs them, for use in change reviews.
+func (p *AWSTgwPlan) String() string {
+	var b strings.Builder
+	for _, name := range p.DomainsCreated {
+		fmt.Fprintf(&b, "+ domain %s\n", name)
+	}
+	for _, conn := range p.ConnectionsAdded {
+		fmt.Fprintf(&b, "+ connection %s <-> %s\n", conn[0], conn[1])
+	}
+	for _, change := range p.VPCsDetached {
+		fmt.Fprintf(&b, "- vpc %s from %s\n", change.VPC.VpcID, change.Domain)
+	}
+	for _, change := range p.VPCsAttached {
+		fmt.Fprintf(&b, "+ vpc %s to %s\n", change.VPC.VpcID, change.Domain)
+	}
+	for _, gwName := range p.TransitGwsDetached {
+		fmt.Fprintf(&b, "- transit gateway %s\n", gwName)
+	}
+	for _, gwName := range p.TransitGwsAttached {
+		fmt.Fprintf(&b, "+ transit gateway %s\n", gwName)
+	}
+	for _, conn := range p.ConnectionsRemoved {
+		fmt.Fprintf(&b, "- connection %s <-> %s\n", conn[0], conn[1])
+	}
+	for _, name := range p.DomainsDeleted {
+		fmt.Fprintf(&b, "- domain %s\n", name)
+	}
+	return b.String()
+}
+
+// PlanAWSTgw compares the transit gateway awsTgw.Name with awsTgw and returns
+// the changes UpdateAWSTgw would make, without making them. SecurityDomains
+// and AttachedAviatrixTransitGW are treated as complete, except that a default
+// domain missing from SecurityDomains is left as it is: it is not deleted,
+// and its connections and VPCs are kept. With ManageDomainFlags set it fails
+// when the flags of an existing domain differ from its rule, as they cannot be
+// changed without recreating the domain.
+func (c *Client) PlanAWSTgw(awsTgw *AWSTgw) (*AWSTgwPlan, error) {
+	if awsTgw.Name == "" {
+		return nil, errors.New("transit gateway name is required")
+	}
+	// a connection may be listed on one of its domains only
+	err := validateSecurityDomains(awsTgw.SecurityDomains, false, func(i, j int) string {
+		return fmt.Sprintf("security_domains[%d].connected_domain[%d]", i, j)
+	})
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.GetAWSTgw(&AWSTgw{Name: awsTgw.Name})
+	if err != nil {
+		return nil, err
+	}
+	if awsTgw.ManageDomainFlags {
+		if err := checkDomainFlags(current, awsTgw); err != nil {
+			return nil, err
+		}
+	}
+	return diffAWSTgw(current, awsTgw), nil
+}
+
+// ApplyAWSTgwPlan makes the changes of a plan returned by PlanAWSTgw. New
+// domains and connections are added first, and connections and domains are
+// removed last, after the VPCs they hold are detached. A VPC can only be attached to one domain, so VPCs are detached
+// before VPCs are attached: a VPC that moves between domains loses
+// connectivity until it is attached to its new domain.
+func (c *Client) ApplyAWSTgwPlan(plan *AWSTgwPlan) error {
+	tgw := &plan.Tgw
+	for _, name := range plan.DomainsCreated {
+		log.Printf("[INFO] Creating security domain %s on %s", name, tgw.Name)
+		if err := c.CreateSecurityDomain(plannedSecurityDomain(tgw, name)); err != nil {
+			return fmt.Errorf("creating security domain %s: %v", name, err)
+		}
+	}
+	for _, conn := range plan.ConnectionsAdded {
+		if err := c.CreateDomainConnection(tgw, conn[0], conn[1]); err != nil {
+			return fmt.Errorf("connecting %s and %s: %v", conn[0], conn[1], err)
+		}
+	}
+	for _, change := range plan.VPCsDetached {
+		if err := c.DetachVpcFromAWSTgw(tgw, change.VPC.VpcID); err != nil {
+			return fmt.Errorf("detaching vpc %s: %v", change.VPC.VpcID, err)
+		}
+	}
+	for _, change := range plan.VPCsAttached {
+		if err := c.AttachVpcToAWSTgw(tgw, change.VPC, change.Domain); err != nil {
+			return fmt.Errorf("attaching vpc %s to %s: %v", change.VPC.VpcID, change.Domain, err)
+		}
+	}
+	for _, gwName := range plan.TransitGwsDetached {
+		err := c.DetachAviatrixTransitGWToAWSTgw(tgw, &Gateway{GwName: gwName}, "Aviatrix_Edge_Domain")
+		if err != nil {
+			return fmt.Errorf("detaching transit gateway %s: %v", gwName, err)
+		}
+	}
+	for _, gwName := range plan.TransitGwsAttached {
+		err := c.AttachAviatrixTransitGWToAWSTgw(tgw, &Gateway{GwName: gwName}, "Aviatrix_Edge_Domain")
+		if err != nil {
+			return fmt.Errorf("attaching transit gateway %s: %v", gwName, err)
+		}
+	}
+	for _, conn := range plan.ConnectionsRemoved {
+		if err := c.DeleteDomainConnection(tgw, conn[0], conn[1]); err != nil {
+			return fmt.Errorf("disconnecting %s and %s: %v", conn[0], conn[1], err)
+		}
+	}
+	for _, name := range plan.DomainsDeleted {
+		log.Printf("[INFO] Deleting security domain %s on %s", name, tgw.Name)
+		err := c.DeleteSecurityDomain(&SecurityDomain{
+			Name:        name,
+			AccountName: tgw.AccountName,
+			Region:      tgw.Region,
+			AwsTgwName:  tgw.Name,
+		})
+		if err != nil {
+			return fmt.Errorf("deleting security domain %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// UpdateAWSTgw reconciles the security domains, domain connections, attached
+// VPCs and attached Aviatrix transit gateways of awsTgw.Name with awsTgw. Use
+// PlanAWSTgw for a dry run.
+func (c *Client) UpdateAWSTgw(awsTgw *AWSTgw) error {
+	plan, err := c.PlanAWSTgw(awsTgw)
+	if err != nil {
+		return err
+	}
+	return c.ApplyAWSTgwPlan(plan)
+}
+
+// diffAWSTgw computes the plan that turns current into desired.
+func diffAWSTgw(current, desired *AWSTgw) *AWSTgwPlan {
+	plan := &AWSTgwPlan{Tgw: *desired}
+
+	currentDomains := make(map[string]*SecurityDomainRule)
+	for i := range current.SecurityDomains {
+		currentDomains[current.SecurityDomains[i].Name] = &current.SecurityDomains[i]
+	}
+	desiredDomains := make(map[string]bool)
+	for _, name := range defaultSecurityDomains {
+		desiredDomains[name] = true
+	}
+	for _, rule := range desired.SecurityDomains {
+		if _, ok := currentDomains[rule.Name]; !ok && !desiredDomains[rule.Name] {
+			plan.DomainsCreated = append(plan.DomainsCreated, rule.Name)
+		}
+		desiredDomains[rule.Name] = true
+	}
+	for _, rule := range current.SecurityDomains {
+		if !desiredDomains[rule.Name] {
+			plan.DomainsDeleted = append(plan.DomainsDeleted, rule.Name)
+		}
+	}
+	sort.Strings(plan.DomainsCreated)
+	sort.Strings(plan.DomainsDeleted)
+
+	// default domains without a rule in desired are left alone
+	unmanaged := make(map[string]bool)
+	for _, name := range defaultSecurityDomains {
+		unmanaged[name] = true
+	}
+	for _, rule := range desired.SecurityDomains {
+		delete(unmanaged, rule.Name)
+	}
+
+	currentConns := domainConnectionSet(current.SecurityDomains)
+	desiredConns := domainConnectionSet(desired.SecurityDomains)
+	for conn := range desiredConns {
+		if !currentConns[conn] {
+			plan.ConnectionsAdded = append(plan.ConnectionsAdded, conn)
+		}
+	}
+	for conn := range currentConns {
+		if !desiredConns[conn] && !unmanaged[conn[0]] && !unmanaged[conn[1]] {
+			plan.ConnectionsRemoved = append(plan.ConnectionsRemoved, conn)
+		}
+	}
+	sortDomainPairs(plan.ConnectionsAdded)
+	sortDomainPairs(plan.ConnectionsRemoved)
+
+	currentVPCs := vpcDomains(current.SecurityDomains)
+	desiredVPCs := vpcDomains(desired.SecurityDomains)
+	for vpcID, change := range currentVPCs {
+		if want, ok := desiredVPCs[vpcID]; (!ok && !unmanaged[change.Domain]) || (ok && want.Domain != change.Domain) {
+			plan.VPCsDetached = append(plan.VPCsDetached, change)
+		}
+	}
+	for vpcID, change := range desiredVPCs {
+		if have, ok := currentVPCs[vpcID]; !ok || have.Domain != change.Domain {
+			plan.VPCsAttached = append(plan.VPCsAttached, change)
+		}
+	}
+	sortVPCChanges(plan.VPCsDetached)
+	sortVPCChanges(plan.VPCsAttached)
+
+	plan.TransitGwsAttached = Difference(desired.AttachedAviatrixTransitGW, current.AttachedAviatrixTransitGW)
+	plan.TransitGwsDetached = Difference(current.AttachedAviatrixTransitGW, desired.AttachedAviatrixTransitGW)
+	sort.Strings(plan.TransitGwsAttached)
+	sort.Strings(plan.TransitGwsDetached)
+	return plan
+}
+
+// checkDomainFlags returns an error for the first domain of desired that
+// exists in current with different flags.
+func checkDomainFlags(current, desired *AWSTgw) error {
+	currentDomains := make(map[string]*SecurityDomainRule)
+	for i := range current.SecurityDomains {
+		currentDomains[current.SecurityDomains[i].Name] = &current.SecurityDomains[i]
+	}
+	for _, rule := range desired.SecurityDomains {
+		have, ok := currentDomains[rule.Name]
+		if ok && (have.FirewallDomain != rule.FirewallDomain || have.NativeEgressDomain != rule.NativeEgressDomain ||
+			have.NativeFirewallDomain != rule.NativeFirewallDomain) {
+			return domainFlagsChangeError(rule.Name)
+		}
+	}
+	return nil
+}
+
+// plannedSecurityDomain returns the domain name of tgw with the flags given
+// for it in tgw.SecurityDomains, or with no flags unless tgw.ManageDomainFlags
+// is set.
+func plannedSecurityDomain(tgw *AWSTgw, name string) *SecurityDomain {
+	securityDomain := &SecurityDomain{
+		Name:        name,
+		AccountName: tgw.AccountName,
+		Region:      tgw.Region,
+		AwsTgwName:  tgw.Name,
+	}
+	if !tgw.ManageDomainFlags {
+		return securityDomain
+	}
+	for _, rule := range tgw.SecurityDomains {
+		if rule.Name == name {
+			securityDomain.FirewallDomain = rule.FirewallDomain
+			securityDomain.NativeEgressDomain = rule.NativeEgressDomain
+			securityDomain.NativeFirewallDomain = rule.NativeFirewallDomain
+			break
+		}
+	}
+	return securityDomain
+}
+
+// domainConnectionSet returns the connections of rules as name pairs sorted
+// within each pair, so that a connection listed on both of its domains is
+// counted once.
+func domainConnectionSet(rules []SecurityDomainRule) map[[2]string]bool {
+	conns := make(map[[2]string]bool)
+	for _, rule := range rules {
+		for _, peer := range rule.ConnectedDomain {
+			pair := [2]string{rule.Name, peer}
+			if pair[1] < pair[0] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			conns[pair] = true
+		}
+	}
+	return conns
+}
+
+func vpcDomains(rules []SecurityDomainRule) map[string]TgwVPCChange {
+	vpcs := make(map[string]TgwVPCChange)
+	for _, rule := range rules {
+		for _, vpc := range rule.AttachedVPCs {
+			vpcs[vpc.VpcID] = TgwVPCChange{Domain: rule.Name, VPC: vpc}
+		}
+	}
+	return vpcs
+}
+
+func sortDomainPairs(pairs [][2]string) {
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+}
+
+func sortVPCChanges(changes []TgwVPCChange) {
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].VPC.VpcID < changes[j].VPC.VpcID
+	})
+}
@@ -0,0 +1,183 @@
+package goaviatrix
+
+import (
+	"log"
+	"strings"
+)
+
+// AWSTgwDetails is the full state of an AWS transit gateway as reported by
+// the controller: every security domain with its route table, routes and
+// attachments, linked to the domains it is connected to.
+type AWSTgwDetails struct {
+	Name        string
+	AccountName string
+	Region      string
+	Domains     []*RouteDomain
+}
+
+// RouteDomain is one security domain of a transit gateway. Connected holds
+// the domains this one is connected to, resolved against the same
+// AWSTgwDetails; ConnectedDomains keeps the names as reported, including any
+// that could not be resolved.
+type RouteDomain struct {
+	Name             string
+	RouteTableID     string
+	Associations     []string
+	ConnectedDomains []string
+	Connected        []*RouteDomain `json:"-"`
+	Attachments      []TgwAttachment
+	Routes           []TgwRoute
+}
+
+// TgwAttachment is a VPC attached to a transit gateway. For attachments of
+// Aviatrix_Edge_Domain, TransitGwName names the Aviatrix transit gateway in
+// the VPC.
+type TgwAttachment struct {
+	AttachmentID  string
+	Domain        string
+	VpcID         string
+	VpcName       string
+	AccountName   string
+	Region        string
+	CIDRs         []string
+	TransitGwName string
+}
+
+// TgwRoute is one entry of a security domain's route table. Type is
+// "propagated" or "static" as reported by AWS.
+type TgwRoute struct {
+	CIDR         string
+	Type         string
+	State        string
+	VpcID        string
+	AttachmentID string
+}
+
+// Propagated reports whether the route was propagated from an attachment
+// rather than added statically.
+func (r TgwRoute) Propagated() bool {
+	return strings.EqualFold(r.Type, "propagated")
+}
+
+// Domain returns the security domain called name, or nil.
+func (d *AWSTgwDetails) Domain(name string) *RouteDomain {
+	for _, domain := range d.Domains {
+		if domain.Name == name {
+			return domain
+		}
+	}
+	return nil
+}
+
+// Connections returns every pair of connected domains once, each pair and
+// the list sorted by name.
+func (d *AWSTgwDetails) Connections() [][2]string {
+	seen := make(map[[2]string]bool)
+	connections := make([][2]string, 0)
+	for _, domain := range d.Domains {
+		for _, peer := range domain.ConnectedDomains {
+			pair := [2]string{domain.Name, peer}
+			if pair[1] < pair[0] {
+				pair[0], pair[1] = pair[1], pair[0]
+			}
+			if !seen[pair] {
+				seen[pair] = true
+				connections = append(connections, pair)
+			}
+		}
+	}
+	sortDomainPairs(connections)
+	return connections
+}
+
+// Attachments returns the attachments of every domain.
+func (d *AWSTgwDetails) Attachments() []TgwAttachment {
+	attachments := make([]TgwAttachment, 0)
+	for _, domain := range d.Domains {
+		attachments = append(attachments, domain.Attachments...)
+	}
+	return attachments
+}
+
+// GetAWSTgwDetails reads every security domain of awsTgw.Name with its route
+// table ID, routes and attachments, and links connected domains to each
+// other.
+func (c *Client) GetAWSTgwDetails(awsTgw *AWSTgw) (*AWSTgwDetails, error) {
+	names, err := c.listRouteDomainNames(awsTgw.Name)
+	if err != nil {
+		return nil, err
+	}
+	details, err := c.getRouteDomainDetails(awsTgw.Name, names)
+	if err != nil {
+		return nil, err
+	}
+
+	tgw := newAWSTgwDetails(awsTgw, details)
+	if edge := tgw.Domain("Aviatrix_Edge_Domain"); edge != nil && len(edge.Attachments) != 0 {
+		transitGws, err := c.listTransitGwNames()
+		if err != nil {
+			return nil, err
+		}
+		for i := range edge.Attachments {
+			gwName, ok := transitGws[edge.Attachments[i].VpcID]
+			if !ok {
+				log.Printf("Couldn't find transit gateway attached to vpc %s", edge.Attachments[i].VpcID)
+				return nil, ErrNotFound
+			}
+			edge.Attachments[i].TransitGwName = gwName
+		}
+	}
+	return tgw, nil
+}
+
+// newAWSTgwDetails builds the domain graph from the decoded
+// view_route_domain_details results.
+func newAWSTgwDetails(awsTgw *AWSTgw, details []*RouteDomainDetail) *AWSTgwDetails {
+	tgw := &AWSTgwDetails{
+		Name:        awsTgw.Name,
+		AccountName: awsTgw.AccountName,
+		Region:      awsTgw.Region,
+		Domains:     make([]*RouteDomain, 0, len(details)),
+	}
+	for _, detail := range details {
+		domain := &RouteDomain{
+			Name:             detail.Name,
+			RouteTableID:     detail.RouteTableId,
+			Associations:     append([]string{}, detail.Associations...),
+			ConnectedDomains: append([]string{}, detail.ConnectedRouteDomain...),
+			Attachments:      make([]TgwAttachment, 0, len(detail.AttachedVPC)),
+			Routes:           make([]TgwRoute, 0, len(detail.RoutesInRouteTable)),
+		}
+		for _, vpc := range detail.AttachedVPC {
+			domain.Attachments = append(domain.Attachments, TgwAttachment{
+				AttachmentID: vpc.AttachmentId,
+				Domain:       detail.Name,
+				VpcID:        vpc.VPCId,
+				VpcName:      vpc.VPCName,
+				AccountName:  vpc.AccountName,
+				Region:       vpc.Region,
+				CIDRs:        append([]string{}, vpc.VPCCidr...),
+			})
+		}
+		for _, route := range detail.RoutesInRouteTable {
+			domain.Routes = append(domain.Routes, TgwRoute{
+				CIDR:         route.CidrBlock,
+				Type:         route.Type,
+				State:        route.State,
+				VpcID:        route.VPCId,
+				AttachmentID: route.TgwAttachmentId,
+			})
+		}
+		tgw.Domains = append(tgw.Domains, domain)
+	}
+
+	for _, domain := range tgw.Domains {
+		domain.Connected = make([]*RouteDomain, 0, len(domain.ConnectedDomains))
+		for _, name := range domain.ConnectedDomains {
+			if peer := tgw.Domain(name); peer != nil {
+				domain.Connected = append(domain.Connected, peer)
+			}
+		}
+	}
+	return tgw
+}
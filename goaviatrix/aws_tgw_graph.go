@@ -0,0 +1,238 @@
+package goaviatrix
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DomainGraph is the connectivity of the security domains of a transit
+// gateway. Transit gateway routing is not transitive: two VPCs can reach each
+// other only when they are in the same domain or in two directly connected
+// domains.
+type DomainGraph struct {
+	domains map[string]*domainNode
+	vpcs    map[string]string
+}
+
+type domainNode struct {
+	name  string
+	vpcs  []string
+	peers map[string]bool
+}
+
+// TransitiveExposure is a pair of domains that are not connected to each
+// other but are both connected to the same hub domain. The VPCs in the hub
+// can reach both and, if they forward traffic, bridge the two.
+type TransitiveExposure struct {
+	Via     string    `json:"via"`
+	Domains [2]string `json:"domains"`
+	VPCs    []string  `json:"vpcs"`
+}
+
+// NewDomainGraph builds the connectivity graph of rules. A connection is
+// taken from either of its domains, and a domain that is only named as a
+// connection has no VPCs.
+func NewDomainGraph(rules []SecurityDomainRule) *DomainGraph {
+	g := &DomainGraph{
+		domains: make(map[string]*domainNode),
+		vpcs:    make(map[string]string),
+	}
+	for _, rule := range rules {
+		node := g.node(rule.Name)
+		for _, vpc := range rule.AttachedVPCs {
+			node.vpcs = append(node.vpcs, vpc.VpcID)
+			g.vpcs[vpc.VpcID] = rule.Name
+		}
+		for _, peer := range rule.ConnectedDomain {
+			if peer == rule.Name {
+				continue
+			}
+			node.peers[peer] = true
+			g.node(peer).peers[rule.Name] = true
+		}
+	}
+	for _, node := range g.domains {
+		sort.Strings(node.vpcs)
+	}
+	return g
+}
+
+func (g *DomainGraph) node(name string) *domainNode {
+	node, ok := g.domains[name]
+	if !ok {
+		node = &domainNode{name: name, peers: make(map[string]bool)}
+		g.domains[name] = node
+	}
+	return node
+}
+
+// Domains returns the names of all domains, sorted.
+func (g *DomainGraph) Domains() []string {
+	names := make([]string, 0, len(g.domains))
+	for name := range g.domains {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Connections returns every pair of connected domains once, each pair and
+// the list sorted by name.
+func (g *DomainGraph) Connections() [][2]string {
+	connections := make([][2]string, 0)
+	for name, node := range g.domains {
+		for peer := range node.peers {
+			if name < peer {
+				connections = append(connections, [2]string{name, peer})
+			}
+		}
+	}
+	sortDomainPairs(connections)
+	return connections
+}
+
+// Connected reports whether domains a and b are directly connected.
+func (g *DomainGraph) Connected(a, b string) bool {
+	node, ok := g.domains[a]
+	return ok && node.peers[b]
+}
+
+// Reachable reports whether the VPCs with IDs a and b can reach each other
+// through the transit gateway. An error is returned if either VPC is not
+// attached.
+func (g *DomainGraph) Reachable(a, b string) (bool, error) {
+	domainA, ok := g.vpcs[a]
+	if !ok {
+		return false, fmt.Errorf("vpc %s is not attached to any security domain", a)
+	}
+	domainB, ok := g.vpcs[b]
+	if !ok {
+		return false, fmt.Errorf("vpc %s is not attached to any security domain", b)
+	}
+	return domainA == domainB || g.Connected(domainA, domainB), nil
+}
+
+// ReachableVPCs returns the IDs of the VPCs that the VPC with ID vpcID can
+// reach, sorted, not including vpcID itself.
+func (g *DomainGraph) ReachableVPCs(vpcID string) ([]string, error) {
+	domain, ok := g.vpcs[vpcID]
+	if !ok {
+		return nil, fmt.Errorf("vpc %s is not attached to any security domain", vpcID)
+	}
+	reachable := make([]string, 0)
+	for other, otherDomain := range g.vpcs {
+		if other != vpcID && (otherDomain == domain || g.Connected(domain, otherDomain)) {
+			reachable = append(reachable, other)
+		}
+	}
+	sort.Strings(reachable)
+	return reachable, nil
+}
+
+// IsolatedDomains returns the domains that are not connected to any other
+// domain, sorted. Their VPCs can only reach each other.
+func (g *DomainGraph) IsolatedDomains() []string {
+	isolated := make([]string, 0)
+	for name, node := range g.domains {
+		if len(node.peers) == 0 {
+			isolated = append(isolated, name)
+		}
+	}
+	sort.Strings(isolated)
+	return isolated
+}
+
+// TransitiveExposures returns the pairs of domains that are connected to
+// Shared_Service_Domain but not to each other, when Shared_Service_Domain has
+// VPCs attached that could forward between them. Domains without VPCs are
+// left out, as there is nothing in them to expose.
+func (g *DomainGraph) TransitiveExposures() []TransitiveExposure {
+	exposures := make([]TransitiveExposure, 0)
+	hub, ok := g.domains["Shared_Service_Domain"]
+	if !ok || len(hub.vpcs) == 0 {
+		return exposures
+	}
+	peers := make([]string, 0, len(hub.peers))
+	for peer := range hub.peers {
+		if len(g.domains[peer].vpcs) != 0 {
+			peers = append(peers, peer)
+		}
+	}
+	sort.Strings(peers)
+	for i := range peers {
+		for j := i + 1; j < len(peers); j++ {
+			if g.Connected(peers[i], peers[j]) {
+				continue
+			}
+			exposures = append(exposures, TransitiveExposure{
+				Via:     hub.name,
+				Domains: [2]string{peers[i], peers[j]},
+				VPCs:    append([]string{}, hub.vpcs...),
+			})
+		}
+	}
+	return exposures
+}
+
+// WriteDOT writes the graph in Graphviz DOT format: one node per domain,
+// labelled with its VPCs, and one undirected edge per connection. Isolated
+// domains are drawn dashed.
+func (g *DomainGraph) WriteDOT(w io.Writer) error {
+	if _, err := fmt.Fprintln(w, "graph security_domains {"); err != nil {
+		return err
+	}
+	for _, name := range g.Domains() {
+		node := g.domains[name]
+		label := name
+		for _, vpc := range node.vpcs {
+			label += "\n" + vpc
+		}
+		attrs := fmt.Sprintf("label=%q", label)
+		if len(node.peers) == 0 {
+			attrs += " style=dashed"
+		}
+		if _, err := fmt.Fprintf(w, "\t%q [%s];\n", name, attrs); err != nil {
+			return err
+		}
+	}
+	for _, conn := range g.Connections() {
+		if _, err := fmt.Fprintf(w, "\t%q -- %q;\n", conn[0], conn[1]); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(w, "}")
+	return err
+}
+
+type domainGraphJSON struct {
+	Domains             []domainNodeJSON     `json:"domains"`
+	Connections         [][2]string          `json:"connections"`
+	TransitiveExposures []TransitiveExposure `json:"transitive_exposures"`
+}
+
+type domainNodeJSON struct {
+	Name     string   `json:"name"`
+	VPCs     []string `json:"vpcs"`
+	Isolated bool     `json:"isolated"`
+}
+
+// MarshalJSON encodes the domains with their VPCs, the connections and the
+// transitive exposures of the graph.
+func (g *DomainGraph) MarshalJSON() ([]byte, error) {
+	out := domainGraphJSON{
+		Domains:             make([]domainNodeJSON, 0, len(g.domains)),
+		Connections:         g.Connections(),
+		TransitiveExposures: g.TransitiveExposures(),
+	}
+	for _, name := range g.Domains() {
+		node := g.domains[name]
+		out.Domains = append(out.Domains, domainNodeJSON{
+			Name:     name,
+			VPCs:     append([]string{}, node.vpcs...),
+			Isolated: len(node.peers) == 0,
+		})
+	}
+	return json.Marshal(out)
+}
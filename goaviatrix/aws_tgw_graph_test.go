@@ -0,0 +1,105 @@
+package goaviatrix
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testDomainGraph() *DomainGraph {
+	return NewDomainGraph([]SecurityDomainRule{
+		{Name: "Aviatrix_Edge_Domain", ConnectedDomain: []string{"Default_Domain"}},
+		{Name: "Default_Domain"},
+		{Name: "Shared_Service_Domain", ConnectedDomain: []string{"prod", "dev", "empty"},
+			AttachedVPCs: []VPCSolo{{VpcID: "vpc-shared"}}},
+		{Name: "prod", AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod2"}, {VpcID: "vpc-prod1"}}},
+		{Name: "dev", AttachedVPCs: []VPCSolo{{VpcID: "vpc-dev"}}},
+		{Name: "empty"},
+		{Name: "lab", AttachedVPCs: []VPCSolo{{VpcID: "vpc-lab"}}},
+	})
+}
+
+func TestDomainGraphReachability(t *testing.T) {
+	g := testDomainGraph()
+
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"vpc-prod1", "vpc-prod2", true},
+		{"vpc-prod1", "vpc-shared", true},
+		{"vpc-dev", "vpc-shared", true},
+		{"vpc-prod1", "vpc-dev", false},
+		{"vpc-lab", "vpc-shared", false},
+	} {
+		got, err := g.Reachable(tc.a, tc.b)
+		assert.Nil(t, err)
+		assert.Equal(t, tc.want, got, "%s -> %s", tc.a, tc.b)
+	}
+	_, err := g.Reachable("vpc-prod1", "vpc-unknown")
+	assert.Error(t, err)
+
+	vpcs, err := g.ReachableVPCs("vpc-shared")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"vpc-dev", "vpc-prod1", "vpc-prod2"}, vpcs)
+
+	assert.Equal(t, []string{"lab"}, g.IsolatedDomains())
+	assert.True(t, g.Connected("Default_Domain", "Aviatrix_Edge_Domain"))
+}
+
+func TestDomainGraphTransitiveExposures(t *testing.T) {
+	g := testDomainGraph()
+	assert.Equal(t, []TransitiveExposure{
+		{Via: "Shared_Service_Domain", Domains: [2]string{"dev", "prod"}, VPCs: []string{"vpc-shared"}},
+	}, g.TransitiveExposures())
+
+	g = NewDomainGraph([]SecurityDomainRule{
+		{Name: "Shared_Service_Domain", ConnectedDomain: []string{"prod", "dev"},
+			AttachedVPCs: []VPCSolo{{VpcID: "vpc-shared"}}},
+		{Name: "prod", ConnectedDomain: []string{"dev"}, AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod1"}}},
+		{Name: "dev", AttachedVPCs: []VPCSolo{{VpcID: "vpc-dev"}}},
+	})
+	assert.Equal(t, 0, len(g.TransitiveExposures()))
+}
+
+func TestDomainGraphExport(t *testing.T) {
+	g := NewDomainGraph([]SecurityDomainRule{
+		{Name: "Shared_Service_Domain", ConnectedDomain: []string{"prod", "dev"},
+			AttachedVPCs: []VPCSolo{{VpcID: "vpc-shared"}}},
+		{Name: "prod", AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod1"}}},
+		{Name: "dev", AttachedVPCs: []VPCSolo{{VpcID: "vpc-dev"}}},
+		{Name: "lab"},
+	})
+
+	var dot bytes.Buffer
+	assert.Nil(t, g.WriteDOT(&dot))
+	assert.Equal(t, `graph security_domains {
+	"Shared_Service_Domain" [label="Shared_Service_Domain\nvpc-shared"];
+	"dev" [label="dev\nvpc-dev"];
+	"lab" [label="lab" style=dashed];
+	"prod" [label="prod\nvpc-prod1"];
+	"Shared_Service_Domain" -- "dev";
+	"Shared_Service_Domain" -- "prod";
+}
+`, dot.String())
+
+	data, err := json.Marshal(g)
+	assert.Nil(t, err)
+	var got, want interface{}
+	assert.Nil(t, json.Unmarshal(data, &got))
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"domains": [
+			{"name": "Shared_Service_Domain", "vpcs": ["vpc-shared"], "isolated": false},
+			{"name": "dev", "vpcs": ["vpc-dev"], "isolated": false},
+			{"name": "lab", "vpcs": [], "isolated": true},
+			{"name": "prod", "vpcs": ["vpc-prod1"], "isolated": false}
+		],
+		"connections": [["Shared_Service_Domain", "dev"], ["Shared_Service_Domain", "prod"]],
+		"transitive_exposures": [
+			{"via": "Shared_Service_Domain", "domains": ["dev", "prod"], "vpcs": ["vpc-shared"]}
+		]
+	}`), &want))
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,185 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"log"
+	"strings"
+)
+
+// TgwCIDROverlap is a CIDR of a VPC being attached that overlaps a CIDR of a
+// VPC it would be able to reach.
+type TgwCIDROverlap struct {
+	CIDR      string
+	OtherCIDR string
+	VpcID     string
+	VpcName   string
+	Domain    string
+}
+
+func (o TgwCIDROverlap) String() string {
+	return fmt.Sprintf("%s overlaps %s of %s in %s", o.CIDR, o.OtherCIDR, o.VpcID, o.Domain)
+}
+
+// TgwOverlapReport is the result of checking a VPC attachment for CIDR
+// overlaps. Reachable lists the IDs of the VPCs the attached VPC could reach:
+// those in Domain and in the domains connected to it. SkippedCIDRs lists the
+// CIDRs of the attached VPC that are not IPv4 and were not checked.
+type TgwOverlapReport struct {
+	VpcID        string
+	Domain       string
+	CIDRs        []string
+	SkippedCIDRs []string
+	Reachable    []string
+	Overlaps     []TgwCIDROverlap
+}
+
+// HasOverlaps reports whether the attachment would make overlapping CIDRs
+// reachable from each other.
+func (r *TgwOverlapReport) HasOverlaps() bool {
+	return len(r.Overlaps) != 0
+}
+
+// TgwCIDROverlapError is returned by AttachVpcToAWSTgwChecked when the
+// attachment is refused because of overlapping CIDRs.
+type TgwCIDROverlapError struct {
+	Report *TgwOverlapReport
+}
+
+func (e *TgwCIDROverlapError) Error() string {
+	overlaps := make([]string, 0, len(e.Report.Overlaps))
+	for _, o := range e.Report.Overlaps {
+		overlaps = append(overlaps, o.String())
+	}
+	return fmt.Sprintf("attaching vpc %s to %s would make overlapping CIDRs reachable: %s", e.Report.VpcID,
+		e.Report.Domain, strings.Join(overlaps, "; "))
+}
+
+// CheckTgwVPCAttachment checks whether attaching the VPC vpcID with the given
+// CIDRs to domain would make it reach a VPC with an overlapping CIDR. When
+// cidrs is empty and the VPC is already attached, the CIDRs reported for that
+// attachment are used. The VPC's own current attachment is not counted. A
+// domain is reachable when either of a connected pair lists the other. CIDRs
+// of the VPC that are not IPv4 are skipped and listed in the report, those of
+// other VPCs are skipped with a warning. It fails when the VPC has no IPv4
+// CIDR to check.
+func CheckTgwVPCAttachment(details *AWSTgwDetails, vpcID string, cidrs []string, domain string,
+) (*TgwOverlapReport, error) {
+	target := details.Domain(domain)
+	if target == nil {
+		return nil, fmt.Errorf("security domain %s does not exist on %s", domain, details.Name)
+	}
+	if len(cidrs) == 0 {
+		for _, attachment := range details.Attachments() {
+			if attachment.VpcID == vpcID {
+				cidrs = attachment.CIDRs
+				break
+			}
+		}
+		if len(cidrs) == 0 {
+			return nil, fmt.Errorf("CIDRs of vpc %s are unknown", vpcID)
+		}
+	}
+	type cidrRange struct {
+		cidr string
+		ipRange
+	}
+	report := &TgwOverlapReport{
+		VpcID:        vpcID,
+		Domain:       domain,
+		CIDRs:        append([]string{}, cidrs...),
+		SkippedCIDRs: make([]string, 0),
+		Reachable:    make([]string, 0),
+		Overlaps:     make([]TgwCIDROverlap, 0),
+	}
+	ranges := make([]cidrRange, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		r, ok, err := parseIPv4Range(strings.TrimSpace(cidr))
+		if !ok || err != nil {
+			report.SkippedCIDRs = append(report.SkippedCIDRs, cidr)
+			continue
+		}
+		ranges = append(ranges, cidrRange{cidr, r})
+	}
+	if len(ranges) == 0 {
+		return nil, fmt.Errorf("vpc %s has no IPv4 CIDR to check: %s", vpcID, strings.Join(cidrs, ", "))
+	}
+
+	for _, d := range reachableDomains(details, target) {
+		for _, attachment := range d.Attachments {
+			if attachment.VpcID == vpcID {
+				continue
+			}
+			report.Reachable = append(report.Reachable, attachment.VpcID)
+			for _, other := range attachment.CIDRs {
+				otherRange, ok, err := parseIPv4Range(strings.TrimSpace(other))
+				if !ok || err != nil {
+					log.Printf("[WARN] Skipping invalid CIDR %q of vpc %s", other, attachment.VpcID)
+					continue
+				}
+				for _, r := range ranges {
+					if r.lo <= otherRange.hi && otherRange.lo <= r.hi {
+						report.Overlaps = append(report.Overlaps, TgwCIDROverlap{
+							CIDR:      r.cidr,
+							OtherCIDR: other,
+							VpcID:     attachment.VpcID,
+							VpcName:   attachment.VpcName,
+							Domain:    d.Name,
+						})
+					}
+				}
+			}
+		}
+	}
+	return report, nil
+}
+
+// reachableDomains returns target followed by the domains connected to it,
+// whichever side of the connection lists it.
+func reachableDomains(details *AWSTgwDetails, target *RouteDomain) []*RouteDomain {
+	domains := append([]*RouteDomain{target}, target.Connected...)
+	for _, d := range details.Domains {
+		if d != target && containsString(d.ConnectedDomains, target.Name) {
+			domains = append(domains, d)
+		}
+	}
+	seen := make(map[string]bool, len(domains))
+	unique := domains[:0]
+	for _, d := range domains {
+		if !seen[d.Name] {
+			seen[d.Name] = true
+			unique = append(unique, d)
+		}
+	}
+	return unique
+}
+
+// CheckVpcAttachmentToAWSTgw reads the current state of awsTgw.Name and
+// checks the attachment of vpcSolo to domain with CheckTgwVPCAttachment.
+func (c *Client) CheckVpcAttachmentToAWSTgw(awsTgw *AWSTgw, vpcSolo VPCSolo, cidrs []string, domain string,
+) (*TgwOverlapReport, error) {
+	details, err := c.GetAWSTgwDetails(awsTgw)
+	if err != nil {
+		return nil, err
+	}
+	return CheckTgwVPCAttachment(details, vpcSolo.VpcID, cidrs, domain)
+}
+
+// AttachVpcToAWSTgwChecked attaches vpcSolo to domain after checking it for
+// CIDR overlaps. Overlaps make it return a *TgwCIDROverlapError without
+// attaching, or, with warnOnly set, are logged and the VPC attached anyway.
+// The report is returned in either case.
+func (c *Client) AttachVpcToAWSTgwChecked(awsTgw *AWSTgw, vpcSolo VPCSolo, cidrs []string, domain string,
+	warnOnly bool) (*TgwOverlapReport, error) {
+	report, err := c.CheckVpcAttachmentToAWSTgw(awsTgw, vpcSolo, cidrs, domain)
+	if err != nil {
+		return nil, err
+	}
+	if report.HasOverlaps() {
+		overlapErr := &TgwCIDROverlapError{Report: report}
+		if !warnOnly {
+			return report, overlapErr
+		}
+		log.Printf("[WARN] %v", overlapErr)
+	}
+	return report, c.AttachVpcToAWSTgw(awsTgw, vpcSolo, domain)
+}
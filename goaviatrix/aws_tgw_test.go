@@ -0,0 +1,442 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listRouteDomainNames = `{
+		"return": true,
+		"results": ["Default_Domain", "Shared_Service_Domain", "prod", "dev"]
+	  }`
+	listTransitVpcsSummary = `{
+		"return": true,
+		"results": [
+			{"vpc_name": "transit-gw", "vpc_id": "vpc-transit~~us-east-1", "transit_vpc": "yes"},
+			{"vpc_name": "spoke-gw", "vpc_id": "vpc-prod1", "transit_vpc": "no"}
+		]
+	  }`
+)
+
+var routeDomainDetails = map[string]string{
+	"Aviatrix_Edge_Domain": `{"return": true, "results": [{
+		"name": "Aviatrix_Edge_Domain",
+		"route_table_id": "tgw-rtb-edge",
+		"connected_route_domain": ["Default_Domain", "Shared_Service_Domain"],
+		"attached_vpc": [{"vpc_id": "vpc-transit", "vpc_name": "transit", "attachment_id": "tgw-attach-t",
+			"account_name": "acct", "region": "us-east-1", "vpc_cidr": ["10.100.0.0/16"]}]
+	}]}`,
+	"Default_Domain": `{"return": true, "results": [{
+		"name": "Default_Domain",
+		"route_table_id": "tgw-rtb-default",
+		"connected_route_domain": ["Aviatrix_Edge_Domain", "Shared_Service_Domain"]
+	}]}`,
+	"Shared_Service_Domain": `{"return": true, "results": [{
+		"name": "Shared_Service_Domain",
+		"route_table_id": "tgw-rtb-shared",
+		"connected_route_domain": ["Aviatrix_Edge_Domain", "Default_Domain", "prod"],
+		"attached_vpc": [{"vpc_id": "vpc-shared", "vpc_name": "shared", "attachment_id": "tgw-attach-s",
+			"account_name": "acct", "region": "us-east-1", "vpc_cidr": ["10.0.0.0/16"]}]
+	}]}`,
+	"prod": `{"return": true, "results": [{
+		"name": "prod",
+		"route_table_id": "tgw-rtb-prod",
+		"associations": ["tgw-attach-p1"],
+		"connected_route_domain": ["Shared_Service_Domain"],
+		"attached_vpc": [{"vpc_id": "vpc-prod1", "vpc_name": "prod1", "attachment_id": "tgw-attach-p1",
+			"account_name": "prod-acct", "region": "us-east-1", "vpc_cidr": ["10.1.0.0/16", "100.64.0.0/24"]}],
+		"routes_in_route_table": [
+			{"cidr_block": "10.1.0.0/16", "type": "propagated", "state": "active", "vpc_id": "vpc-prod1",
+			 "tgw_attachment_id": "tgw-attach-p1"},
+			{"cidr_block": "10.0.0.0/16", "type": "propagated", "state": "active", "vpc_id": "vpc-shared",
+			 "tgw_attachment_id": "tgw-attach-s"},
+			{"cidr_block": "0.0.0.0/0", "type": "static", "state": "blackhole"}
+		]
+	}]}`,
+	"dev": `{"return": true, "results": [{
+		"name": "dev",
+		"route_table_id": "tgw-rtb-dev"
+	}]}`,
+}
+
+// awsTgwTestClient serves the domains of routeDomainDetails for transit
+// gateway "tgw1" and records every other action in calls.
+func awsTgwTestClient(t *testing.T, calls *[]string) (*Client, func()) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_route_domain_names":
+			w.Write([]byte(listRouteDomainNames))
+		case "view_route_domain_details":
+			detail, ok := routeDomainDetails[r.Form.Get("route_domain_name")]
+			if !ok {
+				w.Write([]byte(`{"return": false, "reason": "route domain does not exist"}`))
+				return
+			}
+			w.Write([]byte(detail))
+		case "list_vpcs_summary":
+			if calls != nil {
+				*calls = append(*calls, action)
+			}
+			w.Write([]byte(listTransitVpcsSummary))
+		default:
+			if calls != nil {
+				*calls = append(*calls, action+" "+r.Form.Get("route_domain_name")+
+					r.Form.Get("source_route_domain_name")+r.Form.Get("destination_route_domain_name")+
+					r.Form.Get("vpc_name"))
+			}
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+	return client, teardown
+}
+
+func TestGetAWSTgw(t *testing.T) {
+	client, teardown := awsTgwTestClient(t, nil)
+	defer teardown()
+
+	tgw, err := client.GetAWSTgw(&AWSTgw{Name: "tgw1"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"transit-gw"}, tgw.AttachedAviatrixTransitGW)
+	assert.Equal(t, 5, len(tgw.SecurityDomains))
+	assert.Equal(t, "prod", tgw.SecurityDomains[3].Name)
+	assert.Equal(t, []VPCSolo{{Region: "us-east-1", AccountName: "prod-acct", VpcID: "vpc-prod1"}},
+		tgw.SecurityDomains[3].AttachedVPCs)
+}
+
+func TestGetAWSTgwConcurrentFetch(t *testing.T) {
+	names := make([]string, 30)
+	for i := range names {
+		names[i] = fmt.Sprintf("%q", fmt.Sprintf("domain-%02d", i))
+	}
+	var inFlight, maxInFlight, summaries int32
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_route_domain_names":
+			fmt.Fprintf(w, `{"return": true, "results": [%s]}`, strings.Join(names, ", "))
+		case "view_route_domain_details":
+			n := atomic.AddInt32(&inFlight, 1)
+			defer atomic.AddInt32(&inFlight, -1)
+			for {
+				seen := atomic.LoadInt32(&maxInFlight)
+				if n <= seen || atomic.CompareAndSwapInt32(&maxInFlight, seen, n) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			name := r.Form.Get("route_domain_name")
+			if name == "Aviatrix_Edge_Domain" {
+				w.Write([]byte(routeDomainDetails[name]))
+				return
+			}
+			fmt.Fprintf(w, `{"return": true, "results": [{"name": %q, "attached_vpc": [{"vpc_id": "vpc-%s"}]}]}`,
+				name, name)
+		case "list_vpcs_summary":
+			atomic.AddInt32(&summaries, 1)
+			w.Write([]byte(listTransitVpcsSummary))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	tgw, err := client.GetAWSTgw(&AWSTgw{Name: "tgw1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 31, len(tgw.SecurityDomains))
+	for i, sdr := range tgw.SecurityDomains[1:] {
+		assert.Equal(t, fmt.Sprintf("domain-%02d", i), sdr.Name)
+	}
+	assert.Equal(t, []string{"transit-gw"}, tgw.AttachedAviatrixTransitGW)
+	assert.Equal(t, int32(1), summaries)
+	assert.True(t, maxInFlight > 1)
+	assert.True(t, maxInFlight <= routeDomainFetchConcurrency)
+
+	_, err = client.GetAWSTgwDetails(&AWSTgw{Name: "tgw1"})
+	assert.Nil(t, err)
+	assert.Equal(t, int32(2), summaries)
+}
+
+func TestGetAWSTgwDetailError(t *testing.T) {
+	client, teardown := awsTgwTestClient(t, nil)
+	defer teardown()
+
+	_, err := client.getRouteDomainDetails("tgw1", []string{"prod", "qa", "dev"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "route domain does not exist", err.Error())
+	}
+}
+
+func TestGetAWSTgwDetails(t *testing.T) {
+	client, teardown := awsTgwTestClient(t, nil)
+	defer teardown()
+
+	tgw, err := client.GetAWSTgwDetails(&AWSTgw{Name: "tgw1", Region: "us-east-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(tgw.Domains))
+
+	prod := tgw.Domain("prod")
+	assert.NotNil(t, prod)
+	assert.Equal(t, "tgw-rtb-prod", prod.RouteTableID)
+	assert.Equal(t, []string{"tgw-attach-p1"}, prod.Associations)
+	assert.Equal(t, []TgwAttachment{{
+		AttachmentID: "tgw-attach-p1",
+		Domain:       "prod",
+		VpcID:        "vpc-prod1",
+		VpcName:      "prod1",
+		AccountName:  "prod-acct",
+		Region:       "us-east-1",
+		CIDRs:        []string{"10.1.0.0/16", "100.64.0.0/24"},
+	}}, prod.Attachments)
+	assert.Equal(t, 3, len(prod.Routes))
+	assert.True(t, prod.Routes[0].Propagated())
+	assert.False(t, prod.Routes[2].Propagated())
+	assert.Equal(t, "tgw-attach-s", prod.Routes[1].AttachmentID)
+
+	assert.Equal(t, 1, len(prod.Connected))
+	shared := prod.Connected[0]
+	assert.Equal(t, "Shared_Service_Domain", shared.Name)
+	assert.Equal(t, 3, len(shared.Connected))
+	assert.Equal(t, 0, len(tgw.Domain("dev").Connected))
+
+	edge := tgw.Domain("Aviatrix_Edge_Domain")
+	assert.Equal(t, "transit-gw", edge.Attachments[0].TransitGwName)
+
+	assert.Equal(t, [][2]string{
+		{"Aviatrix_Edge_Domain", "Default_Domain"},
+		{"Aviatrix_Edge_Domain", "Shared_Service_Domain"},
+		{"Default_Domain", "Shared_Service_Domain"},
+		{"Shared_Service_Domain", "prod"},
+	}, tgw.Connections())
+	assert.Equal(t, 3, len(tgw.Attachments()))
+}
+
+func TestDiffAWSTgw(t *testing.T) {
+	current := &AWSTgw{
+		Name:                      "tgw1",
+		AttachedAviatrixTransitGW: []string{"transit-gw"},
+		SecurityDomains: []SecurityDomainRule{
+			{Name: "Default_Domain", ConnectedDomain: []string{"Shared_Service_Domain"}},
+			{Name: "Shared_Service_Domain", ConnectedDomain: []string{"Default_Domain", "prod"}},
+			{Name: "prod", ConnectedDomain: []string{"Shared_Service_Domain"},
+				AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod1"}, {VpcID: "vpc-prod2"}}},
+			{Name: "old", AttachedVPCs: []VPCSolo{{VpcID: "vpc-old"}}},
+		},
+	}
+	desired := &AWSTgw{
+		Name:                      "tgw1",
+		AttachedAviatrixTransitGW: []string{"transit-gw2"},
+		SecurityDomains: []SecurityDomainRule{
+			{Name: "Default_Domain", ConnectedDomain: []string{"Shared_Service_Domain"}},
+			{Name: "prod", AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod1"}}},
+			{Name: "dev", ConnectedDomain: []string{"Shared_Service_Domain"},
+				AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod2"}, {VpcID: "vpc-dev1"}}},
+		},
+	}
+	plan := diffAWSTgw(current, desired)
+	assert.True(t, plan.HasChanges())
+	assert.Equal(t, "+ domain dev\n"+
+		"+ connection Shared_Service_Domain <-> dev\n"+
+		"- vpc vpc-old from old\n"+
+		"- vpc vpc-prod2 from prod\n"+
+		"+ vpc vpc-dev1 to dev\n"+
+		"+ vpc vpc-prod2 to dev\n"+
+		"- transit gateway transit-gw\n"+
+		"+ transit gateway transit-gw2\n"+
+		"- domain old\n", plan.String())
+
+	// Shared_Service_Domain has no rule above, so its connections are kept
+	// until it is given one
+	desired.SecurityDomains = append(desired.SecurityDomains, SecurityDomainRule{
+		Name: "Shared_Service_Domain", ConnectedDomain: []string{"Default_Domain"}})
+	assert.Equal(t, [][2]string{{"Shared_Service_Domain", "prod"}}, diffAWSTgw(current, desired).ConnectionsRemoved)
+
+	assert.False(t, diffAWSTgw(current, current).HasChanges())
+}
+
+func TestUpdateAWSTgw(t *testing.T) {
+	calls := make([]string, 0)
+	client, teardown := awsTgwTestClient(t, &calls)
+	defer teardown()
+
+	desired := &AWSTgw{
+		Name:                      "tgw1",
+		AccountName:               "acct",
+		Region:                    "us-east-1",
+		AttachedAviatrixTransitGW: []string{"transit-gw"},
+		SecurityDomains: []SecurityDomainRule{
+			{Name: "Aviatrix_Edge_Domain", ConnectedDomain: []string{"Default_Domain", "Shared_Service_Domain"}},
+			{Name: "Default_Domain", ConnectedDomain: []string{"Shared_Service_Domain"}},
+			{Name: "Shared_Service_Domain", AttachedVPCs: []VPCSolo{{VpcID: "vpc-shared", Region: "us-east-1", AccountName: "acct"}}},
+			{Name: "prod", ConnectedDomain: []string{"Shared_Service_Domain"}},
+			{Name: "dev", AttachedVPCs: []VPCSolo{{VpcID: "vpc-prod1", Region: "us-east-1", AccountName: "prod-acct"}}},
+			{Name: "test", ConnectedDomain: []string{"dev"}},
+		},
+	}
+	plan, err := client.PlanAWSTgw(desired)
+	assert.Nil(t, err)
+	assert.Equal(t, "+ domain test\n"+
+		"+ connection dev <-> test\n"+
+		"- vpc vpc-prod1 from prod\n"+
+		"+ vpc vpc-prod1 to dev\n", plan.String())
+
+	calls = calls[:0]
+	assert.Nil(t, client.UpdateAWSTgw(desired))
+	assert.Equal(t, []string{
+		"list_vpcs_summary",
+		"add_route_domain test",
+		"add_connection_between_route_domains devtest",
+		"detach_vpc_from_tgw vpc-prod1",
+		"attach_vpc_to_tgw devvpc-prod1",
+	}, calls)
+
+	bad := &AWSTgw{Name: "tgw1", SecurityDomains: []SecurityDomainRule{{Name: "prod", ConnectedDomain: []string{"qa"}}}}
+	_, err = client.PlanAWSTgw(bad)
+	assert.Error(t, err)
+}
+
+// withFirewallDevDomain makes the dev domain of routeDomainDetails a firewall
+// domain until the returned func is called.
+func withFirewallDevDomain() func() {
+	dev := routeDomainDetails["dev"]
+	routeDomainDetails["dev"] = `{"return": true, "results": [{
+		"name": "dev",
+		"route_table_id": "tgw-rtb-dev",
+		"firewall_domain": true
+	}]}`
+	return func() { routeDomainDetails["dev"] = dev }
+}
+
+func TestUpdateAWSTgwDomainFlags(t *testing.T) {
+	defer withFirewallDevDomain()()
+	calls := make([]string, 0)
+	client, teardown := awsTgwTestClient(t, &calls)
+	defer teardown()
+
+	desired, err := client.GetAWSTgw(&AWSTgw{Name: "tgw1", AccountName: "acct", Region: "us-east-1"})
+	if !assert.Nil(t, err) {
+		return
+	}
+	for i := range desired.SecurityDomains {
+		switch desired.SecurityDomains[i].Name {
+		case "dev":
+			desired.SecurityDomains[i].FirewallDomain = false
+		case "prod":
+			desired.SecurityDomains[i].NativeEgressDomain = true
+		}
+	}
+
+	// flags are left alone unless they are managed
+	plan, err := client.PlanAWSTgw(desired)
+	assert.Nil(t, err)
+	assert.False(t, plan.HasChanges())
+
+	// the controller cannot change them, so managed flags that differ fail
+	desired.ManageDomainFlags = true
+	_, err = client.PlanAWSTgw(desired)
+	if assert.Error(t, err) {
+		assert.Equal(t, "changing the flags of security domain prod is not supported; the domain must be "+
+			"recreated", err.Error())
+	}
+
+	// new domains are created with their flags
+	for i := range desired.SecurityDomains {
+		desired.SecurityDomains[i].FirewallDomain = desired.SecurityDomains[i].Name == "dev"
+		desired.SecurityDomains[i].NativeEgressDomain = false
+	}
+	desired.SecurityDomains = append(desired.SecurityDomains, SecurityDomainRule{Name: "egress",
+		NativeEgressDomain: true})
+	plan, err = client.PlanAWSTgw(desired)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, "+ domain egress\n", plan.String())
+	assert.Equal(t, &SecurityDomain{Name: "egress", AccountName: "acct", Region: "us-east-1", AwsTgwName: "tgw1",
+		NativeEgressDomain: true}, plannedSecurityDomain(&plan.Tgw, "egress"))
+}
+
+func TestCheckVpcAttachmentToAWSTgw(t *testing.T) {
+	calls := make([]string, 0)
+	client, teardown := awsTgwTestClient(t, &calls)
+	defer teardown()
+
+	tgw := &AWSTgw{Name: "tgw1", Region: "us-east-1"}
+	vpc := VPCSolo{VpcID: "vpc-new", AccountName: "acct", Region: "us-east-1"}
+	report, err := client.CheckVpcAttachmentToAWSTgw(tgw, vpc, []string{"10.0.128.0/17", "10.2.0.0/16"}, "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"vpc-prod1", "vpc-shared"}, report.Reachable)
+	assert.Equal(t, []TgwCIDROverlap{{CIDR: "10.0.128.0/17", OtherCIDR: "10.0.0.0/16", VpcID: "vpc-shared",
+		VpcName: "shared", Domain: "Shared_Service_Domain"}}, report.Overlaps)
+
+	report, err = client.CheckVpcAttachmentToAWSTgw(tgw, vpc, []string{"10.0.0.0/16"}, "dev")
+	assert.Nil(t, err)
+	assert.False(t, report.HasOverlaps())
+
+	// CIDRs of an attached VPC are taken from its attachment
+	report, err = client.CheckVpcAttachmentToAWSTgw(tgw, VPCSolo{VpcID: "vpc-prod1"}, nil, "Shared_Service_Domain")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"10.1.0.0/16", "100.64.0.0/24"}, report.CIDRs)
+	assert.Equal(t, []string{"vpc-shared", "vpc-transit"}, report.Reachable)
+	assert.False(t, report.HasOverlaps())
+
+	_, err = client.CheckVpcAttachmentToAWSTgw(tgw, vpc, nil, "prod")
+	assert.Error(t, err)
+	_, err = client.CheckVpcAttachmentToAWSTgw(tgw, vpc, []string{"10.9.0.0/16"}, "qa")
+	assert.Error(t, err)
+
+	calls = calls[:0]
+	_, err = client.AttachVpcToAWSTgwChecked(tgw, vpc, []string{"10.1.2.0/24"}, "prod", false)
+	if _, ok := err.(*TgwCIDROverlapError); assert.True(t, ok) {
+		assert.Equal(t, "attaching vpc vpc-new to prod would make overlapping CIDRs reachable: "+
+			"10.1.2.0/24 overlaps 10.1.0.0/16 of vpc-prod1 in prod", err.Error())
+	}
+	assert.Equal(t, []string{"list_vpcs_summary"}, calls)
+
+	calls = calls[:0]
+	report, err = client.AttachVpcToAWSTgwChecked(tgw, vpc, []string{"10.1.2.0/24"}, "prod", true)
+	assert.Nil(t, err)
+	assert.True(t, report.HasOverlaps())
+	assert.Equal(t, []string{"list_vpcs_summary", "attach_vpc_to_tgw prodvpc-new"}, calls)
+}
+
+func TestCheckTgwVPCAttachment(t *testing.T) {
+	shared := &RouteDomain{Name: "shared", Attachments: []TgwAttachment{
+		{VpcID: "vpc-shared", Domain: "shared", CIDRs: []string{"10.0.0.0/16", "2001:db8::/56"}},
+	}}
+	prod := &RouteDomain{Name: "prod", ConnectedDomains: []string{"shared"}, Connected: []*RouteDomain{shared}}
+	details := &AWSTgwDetails{Name: "tgw1", Domains: []*RouteDomain{shared, prod}}
+
+	// shared reaches prod although only prod lists the connection, and IPv6
+	// CIDRs are skipped on both sides
+	report, err := CheckTgwVPCAttachment(details, "vpc-prod1", []string{"2001:db8:1::/56", "10.0.1.0/24"}, "prod")
+	assert.Nil(t, err)
+	assert.Equal(t, []TgwCIDROverlap{{CIDR: "10.0.1.0/24", OtherCIDR: "10.0.0.0/16", VpcID: "vpc-shared",
+		Domain: "shared"}}, report.Overlaps)
+	assert.Equal(t, []string{"2001:db8:1::/56"}, report.SkippedCIDRs)
+
+	// nothing is checked without an IPv4 CIDR
+	_, err = CheckTgwVPCAttachment(details, "vpc-prod1", []string{"2001:db8:1::/56", "10.0.1"}, "prod")
+	assert.Error(t, err)
+
+	prod.Attachments = []TgwAttachment{{VpcID: "vpc-prod1", Domain: "prod", CIDRs: []string{"10.0.2.0/24"}}}
+	report, err = CheckTgwVPCAttachment(details, "vpc-new", []string{"10.0.2.128/25"}, "shared")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"vpc-shared", "vpc-prod1"}, report.Reachable)
+	assert.Equal(t, 2, len(report.Overlaps))
+}
@@ -0,0 +1,140 @@
+package goaviatrix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// SecurityDomainError describes a problem found by ValidateSecurityDomains.
+// Path locates the offending value, as in "security_domains[2].name" or
+// "security_domains[0].connected_domain[1]".
+type SecurityDomainError struct {
+	Path  string
+	Value string
+	Err   error
+}
+
+func (e *SecurityDomainError) Error() string {
+	return fmt.Sprintf("%s (%s): %v", e.Path, e.Value, e.Err)
+}
+
+// SecurityDomainErrors collects every problem found by
+// ValidateSecurityDomains.
+type SecurityDomainErrors []*SecurityDomainError
+
+func (e SecurityDomainErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateSecurityDomains checks the security domains of a transit gateway:
+// names must be set and unique, connections must name another known domain
+// once and be listed on both domains, at most one domain flag may be set and
+// none on a default domain, and each VPC may be attached only once.
+// The default domains are known even when not in rules; connections to them
+// are then not checked for symmetry. All problems are returned together as
+// SecurityDomainErrors.
+func ValidateSecurityDomains(rules []SecurityDomainRule) error {
+	return validateSecurityDomains(rules, true, func(i, j int) string {
+		return fmt.Sprintf("security_domains[%d].connected_domain[%d]", i, j)
+	})
+}
+
+// validateSecurityDomains implements ValidateSecurityDomains. The symmetry of
+// connections is only checked with symmetric set, and connPath names the
+// connection ConnectedDomain[j] of rules[i] in errors.
+func validateSecurityDomains(rules []SecurityDomainRule, symmetric bool, connPath func(i, j int) string) error {
+	var errs SecurityDomainErrors
+	add := func(path, value string, err error) {
+		errs = append(errs, &SecurityDomainError{Path: path, Value: value, Err: err})
+	}
+
+	domains := make(map[string]int)
+	for i, rule := range rules {
+		path := fmt.Sprintf("security_domains[%d].name", i)
+		if rule.Name == "" {
+			add(path, rule.Name, errors.New("name is required"))
+			continue
+		}
+		if j, ok := domains[rule.Name]; ok {
+			add(path, rule.Name, fmt.Errorf("duplicate of security_domains[%d]", j))
+			continue
+		}
+		domains[rule.Name] = i
+		if err := validateSecurityDomainFlags(rule.Name, rule.FirewallDomain, rule.NativeEgressDomain,
+			rule.NativeFirewallDomain); err != nil {
+			add(path, rule.Name, err)
+		}
+	}
+	known := func(name string) bool {
+		if _, ok := domains[name]; ok {
+			return true
+		}
+		for _, dflt := range defaultSecurityDomains {
+			if name == dflt {
+				return true
+			}
+		}
+		return false
+	}
+
+	vpcs := make(map[string]string)
+	for i, rule := range rules {
+		peers := make(map[string]int)
+		for j, peer := range rule.ConnectedDomain {
+			path := connPath(i, j)
+			switch {
+			case peer == "":
+				add(path, peer, errors.New("domain name is required"))
+				continue
+			case peer == rule.Name:
+				add(path, peer, errors.New("connection between same domains"))
+				continue
+			case !known(peer):
+				add(path, peer, errors.New("unrecognized domain name"))
+				continue
+			}
+			if k, ok := peers[peer]; ok {
+				add(path, peer, fmt.Errorf("duplicate of %s", connPath(i, k)))
+				continue
+			}
+			peers[peer] = j
+			if k, ok := domains[peer]; symmetric && ok && rule.Name != "" && domains[rule.Name] == i &&
+				!containsString(rules[k].ConnectedDomain, rule.Name) {
+				add(path, peer, fmt.Errorf("unsymmetric domain connection, %s is not connected to %s", peer,
+					rule.Name))
+			}
+		}
+
+		for j, vpc := range rule.AttachedVPCs {
+			path := fmt.Sprintf("security_domains[%d].attached_vpc[%d]", i, j)
+			if vpc.VpcID == "" {
+				add(path, vpc.VpcID, errors.New("vpc id is required"))
+				continue
+			}
+			if other, ok := vpcs[vpc.VpcID]; ok {
+				add(path, vpc.VpcID, fmt.Errorf("duplicate VPC ID, first attached at %s", other))
+				continue
+			}
+			vpcs[vpc.VpcID] = path
+		}
+	}
+
+	if len(errs) != 0 {
+		return errs
+	}
+	return nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
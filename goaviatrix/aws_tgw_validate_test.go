@@ -0,0 +1,128 @@
+package goaviatrix
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateSecurityDomains(t *testing.T) {
+	assert.Nil(t, ValidateSecurityDomains([]SecurityDomainRule{
+		{Name: "Shared_Service_Domain", ConnectedDomain: []string{"prod"}},
+		{Name: "prod", ConnectedDomain: []string{"Shared_Service_Domain", "Default_Domain"},
+			AttachedVPCs: []VPCSolo{{VpcID: "vpc-1"}}},
+	}))
+
+	err := ValidateSecurityDomains([]SecurityDomainRule{
+		{Name: "prod", ConnectedDomain: []string{"dev", "prod", "qa", "dev"},
+			AttachedVPCs: []VPCSolo{{VpcID: "vpc-1"}, {VpcID: ""}}},
+		{Name: "dev", AttachedVPCs: []VPCSolo{{VpcID: "vpc-1"}}},
+		{Name: "prod"},
+		{Name: ""},
+	})
+	errs, ok := err.(SecurityDomainErrors)
+	if !assert.True(t, ok) {
+		return
+	}
+	paths := make([]string, 0, len(errs))
+	for _, e := range errs {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{
+		"security_domains[2].name",
+		"security_domains[3].name",
+		"security_domains[0].connected_domain[0]",
+		"security_domains[0].connected_domain[1]",
+		"security_domains[0].connected_domain[2]",
+		"security_domains[0].connected_domain[3]",
+		"security_domains[0].attached_vpc[1]",
+		"security_domains[1].attached_vpc[0]",
+	}, paths)
+	assert.Equal(t, "security_domains[2].name (prod): duplicate of security_domains[0]", errs[0].Error())
+	assert.True(t, strings.Contains(errs[2].Error(), "unsymmetric domain connection"))
+	assert.True(t, strings.Contains(errs[7].Error(), "first attached at security_domains[0].attached_vpc[0]"))
+
+	err = ValidateSecurityDomains([]SecurityDomainRule{
+		{Name: "Default_Domain", NativeEgressDomain: true},
+		{Name: "fw", FirewallDomain: true, NativeFirewallDomain: true},
+		{Name: "egress", NativeEgressDomain: true},
+	})
+	if errs, ok := err.(SecurityDomainErrors); assert.True(t, ok) && assert.Equal(t, 2, len(errs)) {
+		assert.Equal(t, "security_domains[0].name", errs[0].Path)
+		assert.Equal(t, "security_domains[1].name", errs[1].Path)
+	}
+}
+
+func TestValidateAWSTgwDomains(t *testing.T) {
+	client := &Client{}
+	domains := []string{"prod", "Shared_Service_Domain", "Default_Domain", "Aviatrix_Edge_Domain", "dev"}
+	create, policy, remove, err := client.ValidateAWSTgwDomains(domains, [][]string{
+		{"Aviatrix_Edge_Domain", "Default_Domain"},
+		{"Default_Domain", "Aviatrix_Edge_Domain"},
+		{"prod", "Shared_Service_Domain"},
+		{"Shared_Service_Domain", "prod"},
+	}, [][]string{{"prod", "vpc-1"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"dev", "prod"}, create)
+	assert.Equal(t, [][]string{{"prod", "Shared_Service_Domain"}}, policy)
+	assert.Equal(t, [][]string{
+		{"Default_Domain", "Shared_Service_Domain"},
+		{"Shared_Service_Domain", "Aviatrix_Edge_Domain"},
+	}, remove)
+
+	_, _, _, err = client.ValidateAWSTgwDomains([]string{"prod"}, [][]string{{"prod"}, {"qa", "prod"},
+		{"prod", "Default_Domain"}}, [][]string{{"vpc-1"}, {"prod", "vpc-2"}, {"dev", "vpc-2"}})
+	errs, ok := err.(SecurityDomainErrors)
+	if assert.True(t, ok) {
+		assert.Equal(t, 4, len(errs))
+	}
+
+	_, _, _, err = client.ValidateAWSTgwDomains([]string{"dev", "prod", "qa"}, [][]string{{"prod", "dev"},
+		{"dev", "prod"}, {"prod", "dev"}, {"prod", "qa"}}, nil)
+	if errs, ok := err.(SecurityDomainErrors); assert.True(t, ok) && assert.Equal(t, 2, len(errs)) {
+		assert.Equal(t, "domain_connections[2] (dev): duplicate of domain_connections[0]", errs[0].Error())
+		assert.Equal(t, "domain_connections[3]", errs[1].Path)
+		assert.True(t, strings.Contains(errs[1].Error(), "unsymmetric domain connection"))
+	}
+}
+
+func FuzzValidateSecurityDomains(f *testing.F) {
+	f.Add("prod:Shared_Service_Domain:vpc-1\nShared_Service_Domain:prod:")
+	f.Add("prod:prod,qa,:vpc-1,vpc-1\n:dev:\nprod::")
+	f.Add("Default_Domain:Aviatrix_Edge_Domain:\n\n::,")
+	f.Fuzz(func(t *testing.T, input string) {
+		// each line is name:connected,domains:vpc,ids
+		var rules []SecurityDomainRule
+		var domains []string
+		var conns, vpcs [][]string
+		for _, line := range strings.Split(input, "\n") {
+			fields := strings.SplitN(line, ":", 3)
+			rule := SecurityDomainRule{Name: fields[0]}
+			domains = append(domains, fields[0])
+			if len(fields) > 1 {
+				rule.ConnectedDomain = strings.Split(fields[1], ",")
+				for _, peer := range rule.ConnectedDomain {
+					conns = append(conns, []string{fields[0], peer}[:1+len(peer)%2])
+				}
+			}
+			if len(fields) > 2 {
+				for _, vpc := range strings.Split(fields[2], ",") {
+					rule.AttachedVPCs = append(rule.AttachedVPCs, VPCSolo{VpcID: vpc})
+					vpcs = append(vpcs, []string{fields[0], vpc}[:1+len(vpc)%2])
+				}
+			}
+			rules = append(rules, rule)
+		}
+
+		if err := ValidateSecurityDomains(rules); err != nil {
+			errs, ok := err.(SecurityDomainErrors)
+			if !ok || len(errs) == 0 {
+				t.Fatalf("want SecurityDomainErrors, got %#v", err)
+			}
+		} else if len(NewDomainGraph(rules).Domains()) < len(rules) {
+			t.Fatalf("valid rules with duplicate names: %q", input)
+		}
+		(&Client{}).ValidateAWSTgwDomains(domains, conns, vpcs)
+	})
+}
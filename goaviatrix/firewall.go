@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"strings"
 )
 
 type Policy struct {
@@ -54,19 +55,31 @@ func (c *Client) SetBasePolicy(firewall *Firewall) error {
 	return nil
 }
 
+// updatePolicyForm is the POST body of update_access_policy. The rules are
+// sent as JSON in new_policy, form-encoded along with the rest of the body
+// rather than appended to the URL unescaped.
+type updatePolicyForm struct {
+	CID       string `form:"CID"`
+	Action    string `form:"action"`
+	GwName    string `form:"vpc_name"`
+	NewPolicy string `form:"new_policy"`
+}
+
 func (c *Client) UpdatePolicy(firewall *Firewall) error {
 	firewall.CID = c.CID
 	firewall.Action = "update_access_policy"
-	path := c.baseURL + fmt.Sprintf("?CID=%s&action=update_access_policy&vpc_name=%s&new_policy=", c.CID,
-		firewall.GwName)
 	log.Printf("[INFO] Updating Aviatrix firewall for gateway: %#v", firewall)
 
 	args, err := json.Marshal(firewall.PolicyList)
 	if err != nil {
 		return err
 	}
-	path = path + string(args)
-	resp, err := c.Get(path, nil)
+	resp, err := c.Post(c.baseURL, &updatePolicyForm{
+		CID:       c.CID,
+		Action:    firewall.Action,
+		GwName:    firewall.GwName,
+		NewPolicy: string(args),
+	})
 	if err != nil {
 		return err
 	}
@@ -81,6 +94,33 @@ func (c *Client) UpdatePolicy(firewall *Firewall) error {
 }
 
 func (c *Client) GetPolicy(firewall *Firewall) (*Firewall, error) {
+	policy, err := c.getPolicy(firewall)
+	if reason, ok := err.(policyReasonError); ok {
+		log.Printf("[INFO] Couldn't find Aviatrix Firewall policies for gateway %s: %s", firewall.GwName,
+			reason)
+		return nil, ErrNotFound
+	}
+	return policy, err
+}
+
+// policyReasonError is the reason given by the controller for not returning
+// a gateway's firewall policy.
+type policyReasonError string
+
+func (e policyReasonError) Error() string {
+	return string(e)
+}
+
+// noPolicy reports whether err is the controller's answer for a gateway
+// that has no firewall policy, rather than a failure to read one.
+func noPolicy(err error) bool {
+	reason, ok := err.(policyReasonError)
+	return ok && strings.Contains(string(reason), "does not exist")
+}
+
+// getPolicy is GetPolicy returning the controller's reason as a
+// policyReasonError instead of ErrNotFound.
+func (c *Client) getPolicy(firewall *Firewall) (*Firewall, error) {
 	path := c.baseURL + fmt.Sprintf("?CID=%s&action=vpc_access_policy&vpc_name=%s", c.CID, firewall.GwName)
 	log.Printf("[INFO] Getting Policy: %#v", firewall)
 
@@ -93,9 +133,7 @@ func (c *Client) GetPolicy(firewall *Firewall) (*Firewall, error) {
 		return nil, err
 	}
 	if !data.Return {
-		log.Printf("[INFO] Couldn't find Aviatrix Firewall policies for gateway %s: %s", firewall.GwName,
-			data.Reason)
-		return nil, ErrNotFound
+		return nil, policyReasonError(data.Reason)
 	}
 	if data.Results.BaseAllowDeny == "allow-all" {
 		data.Results.BaseAllowDeny = "allow"
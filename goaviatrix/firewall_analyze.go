@@ -0,0 +1,448 @@
+package goaviatrix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// PolicyFindingType classifies a PolicyFinding.
+type PolicyFindingType string
+
+const (
+	// PolicyInvalid rules could not be parsed and were left out of the analysis.
+	PolicyInvalid PolicyFindingType = "invalid"
+	// PolicyUnknownTag rules reference a firewall tag that was not supplied.
+	// The traffic they match is unknown, so they are left out of the analysis.
+	PolicyUnknownTag PolicyFindingType = "unknown-tag"
+	// PolicyEmptyTag rules reference a firewall tag without members. They
+	// match no traffic and are left out of the analysis.
+	PolicyEmptyTag PolicyFindingType = "empty-tag"
+	// PolicyDuplicate rules are identical to an earlier rule.
+	PolicyDuplicate PolicyFindingType = "duplicate"
+	// PolicyShadowed rules are fully covered by an earlier rule with the
+	// opposite action, so they never take effect.
+	PolicyShadowed PolicyFindingType = "shadowed"
+	// PolicyRedundant rules are fully covered by an earlier rule with the same
+	// action, so removing them changes nothing.
+	PolicyRedundant PolicyFindingType = "redundant"
+	// PolicyConflict rules partially overlap an earlier rule with the opposite
+	// action, so their relative order decides some traffic.
+	PolicyConflict PolicyFindingType = "conflict"
+	// PolicyBaseRedundant rules only repeat what the base policy would do for
+	// the traffic they match.
+	PolicyBaseRedundant PolicyFindingType = "base-redundant"
+)
+
+// PolicyFinding is one problem found by AnalyzePolicies. Index is the
+// position of the offending rule in Firewall.PolicyList and Related the
+// position of the earlier rule involved, or -1.
+type PolicyFinding struct {
+	Type    PolicyFindingType
+	Index   int
+	Related int
+	Message string
+}
+
+func (f PolicyFinding) String() string {
+	return fmt.Sprintf("%s: %s", f.Type, f.Message)
+}
+
+// AnalyzePolicies inspects a gateway's ordered rules without contacting the
+// controller. Rules are evaluated top-down with the first match winning and
+// unmatched traffic falling through to BaseAllowDeny. Sources and destinations
+// may be IPv4 addresses, CIDRs or names of the supplied firewall tags. Rules
+// naming a tag missing from tags or a tag without members are reported and not
+// compared with other rules. When tags is nil, names are compared by name
+// only. Findings are ordered by rule index.
+func AnalyzePolicies(firewall *Firewall, tags []*FirewallTag) []PolicyFinding {
+	resolver := newTagResolver(tags)
+	findings := make([]PolicyFinding, 0)
+	rules := make([]*policyMatch, len(firewall.PolicyList))
+	for i, p := range firewall.PolicyList {
+		rule, unknown, empty, err := parsePolicyMatch(p, resolver)
+		if err != nil {
+			findings = append(findings, PolicyFinding{
+				Type:    PolicyInvalid,
+				Index:   i,
+				Related: -1,
+				Message: fmt.Sprintf("rule %d: %v", i, err),
+			})
+			continue
+		}
+		for _, name := range unknown {
+			findings = append(findings, PolicyFinding{
+				Type:    PolicyUnknownTag,
+				Index:   i,
+				Related: -1,
+				Message: fmt.Sprintf("rule %d references unknown firewall tag %q", i, name),
+			})
+		}
+		for _, name := range empty {
+			findings = append(findings, PolicyFinding{
+				Type:    PolicyEmptyTag,
+				Index:   i,
+				Related: -1,
+				Message: fmt.Sprintf("rule %d references firewall tag %q, which has no members", i, name),
+			})
+		}
+		if len(unknown) == 0 && len(empty) == 0 {
+			rules[i] = rule
+		}
+	}
+
+	// Rules found to be covered by an earlier rule never match anything, so
+	// later rules are not compared against them.
+	covered := make([]bool, len(rules))
+	base := shortBasePolicy(strings.ToLower(firewall.BaseAllowDeny))
+	for j, rule := range rules {
+		if rule == nil {
+			continue
+		}
+		for i := 0; i < j && !covered[j]; i++ {
+			earlier := rules[i]
+			if earlier == nil || covered[i] {
+				continue
+			}
+			switch {
+			case earlier.key == rule.key:
+				findings = append(findings, PolicyFinding{
+					Type:    PolicyDuplicate,
+					Index:   j,
+					Related: i,
+					Message: fmt.Sprintf("rule %d duplicates rule %d", j, i),
+				})
+				covered[j] = true
+			case rule.subsetOf(earlier) && rule.allow != earlier.allow:
+				findings = append(findings, PolicyFinding{
+					Type:    PolicyShadowed,
+					Index:   j,
+					Related: i,
+					Message: fmt.Sprintf("rule %d is shadowed by rule %d and never takes effect", j, i),
+				})
+				covered[j] = true
+			case rule.subsetOf(earlier):
+				findings = append(findings, PolicyFinding{
+					Type:    PolicyRedundant,
+					Index:   j,
+					Related: i,
+					Message: fmt.Sprintf("rule %d is already covered by rule %d", j, i),
+				})
+				covered[j] = true
+			case rule.allow != earlier.allow && rule.overlaps(earlier) && !earlier.subsetOf(rule):
+				findings = append(findings, PolicyFinding{
+					Type:    PolicyConflict,
+					Index:   j,
+					Related: i,
+					Message: fmt.Sprintf("rule %d partially overlaps rule %d with the opposite action", j, i),
+				})
+			}
+		}
+		if covered[j] || base == "" || rule.allow != (base == "allow") {
+			continue
+		}
+		if rule.log && firewall.BaseLogEnable != "on" {
+			continue
+		}
+		// Without the rule its traffic goes on to the later rules and only
+		// reaches the base policy when none of them match, so the rule repeats
+		// the base policy unless a later rule with the opposite action would
+		// catch some of its traffic. Later rules it covers count too, as they
+		// take effect once it is removed.
+		overridden := false
+		for k := j + 1; k < len(rules); k++ {
+			if rules[k] != nil && rules[k].allow != rule.allow && rules[k].overlaps(rule) {
+				overridden = true
+				break
+			}
+		}
+		if !overridden {
+			findings = append(findings, PolicyFinding{
+				Type:    PolicyBaseRedundant,
+				Index:   j,
+				Related: -1,
+				Message: fmt.Sprintf("rule %d has the same effect as the %s base policy", j, base),
+			})
+		}
+	}
+
+	sort.SliceStable(findings, func(a, b int) bool { return findings[a].Index < findings[b].Index })
+	return findings
+}
+
+// policyMatch is the parsed traffic space of a Policy.
+type policyMatch struct {
+	key      string
+	src      addrSet
+	dst      addrSet
+	protocol string // "" matches every protocol
+	ports    portSet
+	allow    bool
+	log      bool
+}
+
+func parsePolicyMatch(p *Policy, resolver *tagResolver) (*policyMatch, []string, []string, error) {
+	normalized := *p
+	if err := normalizePolicy(&normalized); err != nil {
+		return nil, nil, nil, err
+	}
+	rule := &policyMatch{
+		key:   policyKey(&normalized),
+		allow: normalized.AllowDeny == "allow",
+		log:   normalized.LogEnable == "on",
+	}
+	var unknown, empty []string
+	var err error
+	var srcUnknown, dstUnknown bool
+	src, dst := strings.TrimSpace(p.SrcIP), strings.TrimSpace(p.DstIP)
+	if rule.src, srcUnknown, err = resolver.resolve(src); err != nil {
+		return nil, nil, nil, fmt.Errorf("source: %v", err)
+	}
+	if srcUnknown {
+		unknown = append(unknown, src)
+	} else if rule.src.isEmpty() {
+		empty = append(empty, src)
+	}
+	if rule.dst, dstUnknown, err = resolver.resolve(dst); err != nil {
+		return nil, nil, nil, fmt.Errorf("destination: %v", err)
+	}
+	if dstUnknown {
+		unknown = append(unknown, dst)
+	} else if rule.dst.isEmpty() && dst != src {
+		empty = append(empty, dst)
+	}
+
+	switch normalized.Protocol {
+	case "all", "any":
+		rule.protocol = ""
+	default:
+		rule.protocol = normalized.Protocol
+	}
+	if rule.protocol == "icmp" {
+		rule.ports = anyPort()
+	} else if rule.ports, err = parsePortSet(p.Port); err != nil {
+		return nil, nil, nil, err
+	}
+	return rule, unknown, empty, nil
+}
+
+func (r *policyMatch) subsetOf(o *policyMatch) bool {
+	return (o.protocol == "" || o.protocol == r.protocol) &&
+		r.src.subsetOf(o.src) && r.dst.subsetOf(o.dst) && r.ports.subsetOf(o.ports)
+}
+
+func (r *policyMatch) overlaps(o *policyMatch) bool {
+	return (o.protocol == "" || r.protocol == "" || o.protocol == r.protocol) &&
+		r.src.overlaps(o.src) && r.dst.overlaps(o.dst) && r.ports.overlaps(o.ports)
+}
+
+// tagResolver maps firewall tag names to their member CIDRs.
+type tagResolver struct {
+	tags map[string][]string
+}
+
+func newTagResolver(tags []*FirewallTag) *tagResolver {
+	resolver := &tagResolver{}
+	if tags == nil {
+		return resolver
+	}
+	resolver.tags = make(map[string][]string)
+	for _, tag := range tags {
+		cidrs := make([]string, 0, len(tag.CIDRList))
+		for _, member := range tag.CIDRList {
+			cidrs = append(cidrs, member.CIDR)
+		}
+		resolver.tags[tag.Name] = cidrs
+	}
+	return resolver
+}
+
+// resolve parses an address, CIDR or tag name. The returned flag is set when
+// value is a name the resolver has no tag for, in which case the set only
+// matches the same name.
+func (t *tagResolver) resolve(value string) (addrSet, bool, error) {
+	if value == "" {
+		return addrSet{}, false, errors.New("empty address")
+	}
+	if r, ok, err := parseIPv4Range(value); ok {
+		if err != nil {
+			return addrSet{}, false, err
+		}
+		return addrSet{ranges: []ipRange{r}}, false, nil
+	}
+	if cidrs, ok := t.tags[value]; ok {
+		set := addrSet{}
+		for _, cidr := range cidrs {
+			r, isAddr, err := parseIPv4Range(strings.TrimSpace(cidr))
+			if !isAddr || err != nil {
+				return addrSet{}, false, fmt.Errorf("firewall tag %s has invalid member %q", value, cidr)
+			}
+			set.ranges = append(set.ranges, r)
+		}
+		set.ranges = mergeIPRanges(set.ranges)
+		return set, false, nil
+	}
+	return addrSet{symbol: value}, t.tags != nil, nil
+}
+
+// ipRange is an inclusive range of IPv4 addresses.
+type ipRange struct {
+	lo, hi uint32
+}
+
+// parseIPv4Range parses an IPv4 address or CIDR. ok is false when value does
+// not look like an address at all, so it can be treated as a tag name.
+func parseIPv4Range(value string) (r ipRange, ok bool, err error) {
+	if value == "any" {
+		return ipRange{0, 0xffffffff}, true, nil
+	}
+	addr := value
+	if i := strings.IndexByte(value, '/'); i >= 0 {
+		addr = value[:i]
+	}
+	if net.ParseIP(addr) == nil {
+		return ipRange{}, false, nil
+	}
+	if !strings.Contains(value, "/") {
+		value += "/32"
+	}
+	_, ipNet, err := net.ParseCIDR(value)
+	if err != nil {
+		return ipRange{}, true, fmt.Errorf("invalid CIDR %q", value)
+	}
+	ip4 := ipNet.IP.To4()
+	if ip4 == nil || len(ipNet.Mask) != net.IPv4len {
+		return ipRange{}, true, fmt.Errorf("%q is not an IPv4 CIDR", value)
+	}
+	lo := binary.BigEndian.Uint32(ip4)
+	return ipRange{lo, lo | ^binary.BigEndian.Uint32(ipNet.Mask)}, true, nil
+}
+
+// mergeIPRanges sorts ranges and joins overlapping and adjacent ones.
+func mergeIPRanges(ranges []ipRange) []ipRange {
+	if len(ranges) == 0 {
+		return ranges
+	}
+	sorted := append([]ipRange{}, ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].lo < sorted[j].lo })
+	merged := []ipRange{sorted[0]}
+	for _, r := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if last.hi == 0xffffffff || r.lo <= last.hi+1 {
+			if r.hi > last.hi {
+				last.hi = r.hi
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// addrSet is a set of IPv4 addresses, or an opaque name when a tag could not
+// be resolved.
+type addrSet struct {
+	ranges []ipRange // merged
+	symbol string
+}
+
+// isEmpty reports whether a holds no addresses, as for a tag without members.
+func (a addrSet) isEmpty() bool {
+	return a.symbol == "" && len(a.ranges) == 0
+}
+
+func (a addrSet) isAny() bool {
+	return len(a.ranges) == 1 && a.ranges[0].lo == 0 && a.ranges[0].hi == 0xffffffff
+}
+
+func (a addrSet) subsetOf(b addrSet) bool {
+	if b.isAny() {
+		return true
+	}
+	if a.symbol != "" || b.symbol != "" {
+		return a.symbol != "" && a.symbol == b.symbol
+	}
+	for _, r := range a.ranges {
+		if !b.containsRange(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func (a addrSet) overlaps(b addrSet) bool {
+	if a.symbol != "" || b.symbol != "" {
+		return a.symbol == b.symbol || (a.symbol == "" && a.isAny()) || (b.symbol == "" && b.isAny())
+	}
+	i, j := 0, 0
+	for i < len(a.ranges) && j < len(b.ranges) {
+		if a.ranges[i].hi < b.ranges[j].lo {
+			i++
+		} else if b.ranges[j].hi < a.ranges[i].lo {
+			j++
+		} else {
+			return true
+		}
+	}
+	return false
+}
+
+func (a addrSet) containsRange(r ipRange) bool {
+	for _, x := range a.ranges {
+		if x.lo <= r.lo && r.hi <= x.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// portSet is a merged, sorted list of inclusive port ranges.
+type portSet []ipRange
+
+func anyPort() portSet {
+	return portSet{{0, 65535}}
+}
+
+// parsePortSet parses the port field of a rule: empty, "all" or "any" for
+// every port, a single port, a range written "a:b" or "a-b", or a
+// comma-separated list of those.
+func parsePortSet(value string) (portSet, error) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if value == "" || value == "all" || value == "any" {
+		return anyPort(), nil
+	}
+	ranges := make([]ipRange, 0)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		bounds := strings.Split(strings.Replace(part, ":", "-", 1), "-")
+		if len(bounds) > 2 || bounds[0] == "" || bounds[len(bounds)-1] == "" {
+			return nil, fmt.Errorf("invalid port %q", part)
+		}
+		lo, err := parsePort(bounds[0])
+		if err != nil {
+			return nil, err
+		}
+		hi := lo
+		if len(bounds) == 2 {
+			if hi, err = parsePort(bounds[1]); err != nil {
+				return nil, err
+			}
+		}
+		if lo > hi {
+			return nil, fmt.Errorf("invalid port range %q", part)
+		}
+		ranges = append(ranges, ipRange{uint32(lo), uint32(hi)})
+	}
+	return portSet(mergeIPRanges(ranges)), nil
+}
+
+func (p portSet) subsetOf(o portSet) bool {
+	return addrSet{ranges: p}.subsetOf(addrSet{ranges: o}) || (len(o) == 1 && o[0] == ipRange{0, 65535})
+}
+
+func (p portSet) overlaps(o portSet) bool {
+	return addrSet{ranges: p}.overlaps(addrSet{ranges: o})
+}
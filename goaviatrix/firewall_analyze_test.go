@@ -0,0 +1,144 @@
+package goaviatrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func findingTypes(findings []PolicyFinding) map[int][]PolicyFindingType {
+	types := make(map[int][]PolicyFindingType)
+	for _, f := range findings {
+		types[f.Index] = append(types[f.Index], f.Type)
+	}
+	return types
+}
+
+func TestAnalyzePolicies(t *testing.T) {
+	tags := []*FirewallTag{
+		{Name: "web", CIDRList: []CIDRMember{
+			{CIDRTag: "web-a", CIDR: "10.1.0.0/25"},
+			{CIDRTag: "web-b", CIDR: "10.1.0.128/25"},
+		}},
+	}
+	firewall := &Firewall{
+		GwName:        "gw1",
+		BaseAllowDeny: "deny",
+		BaseLogEnable: "off",
+		PolicyList: []*Policy{
+			// 0: allow web to anything on 443
+			{SrcIP: "web", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "443", AllowDeny: "allow", LogEnable: "off"},
+			// 1: exact duplicate of 0
+			{SrcIP: "web", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "443", AllowDeny: "allow", LogEnable: "off"},
+			// 2: inside 0 with the opposite action
+			{SrcIP: "10.1.0.5", DstIP: "10.2.0.0/16", Protocol: "tcp", Port: "443", AllowDeny: "deny", LogEnable: "off"},
+			// 3: inside 0 with the same action
+			{SrcIP: "10.1.0.0/24", DstIP: "10.3.0.0/16", Protocol: "TCP", Port: "443:443", AllowDeny: "allow", LogEnable: "on"},
+			// 4: partially overlaps 0, and no later allow rule catches its
+			// traffic, so it also repeats the deny base policy
+			{SrcIP: "10.0.0.0/8", DstIP: "10.4.0.0/16", Protocol: "all", Port: "", AllowDeny: "deny", LogEnable: "off"},
+			// 5: repeats the deny base policy
+			{SrcIP: "192.168.0.0/16", DstIP: "10.5.0.0/16", Protocol: "udp", Port: "53", AllowDeny: "deny", LogEnable: "off"},
+			// 6: unparseable
+			{SrcIP: "10.0.0.0/33", DstIP: "10.5.0.0/16", Protocol: "udp", Port: "53", AllowDeny: "deny", LogEnable: "off"},
+			// 7: unknown tag
+			{SrcIP: "db", DstIP: "10.5.0.0/16", Protocol: "tcp", Port: "5432", AllowDeny: "allow", LogEnable: "off"},
+			// 8: port out of range
+			{SrcIP: "10.0.0.0/8", DstIP: "10.5.0.0/16", Protocol: "tcp", Port: "1-70000", AllowDeny: "allow", LogEnable: "off"},
+		},
+	}
+	findings := AnalyzePolicies(firewall, tags)
+	assert.Equal(t, map[int][]PolicyFindingType{
+		1: {PolicyDuplicate},
+		2: {PolicyShadowed},
+		3: {PolicyRedundant},
+		4: {PolicyConflict, PolicyBaseRedundant},
+		5: {PolicyBaseRedundant},
+		6: {PolicyInvalid},
+		7: {PolicyUnknownTag},
+		8: {PolicyInvalid},
+	}, findingTypes(findings))
+	assert.Equal(t, 0, findings[0].Related)
+	assert.Equal(t, "shadowed: rule 2 is shadowed by rule 0 and never takes effect", findings[1].String())
+}
+
+func TestAnalyzePoliciesWithoutTags(t *testing.T) {
+	firewall := &Firewall{
+		BaseAllowDeny: "allow-all",
+		PolicyList: []*Policy{
+			{SrcIP: "web", DstIP: "10.0.0.0/8", Protocol: "tcp", Port: "22", AllowDeny: "deny"},
+			{SrcIP: "web", DstIP: "10.1.0.0/16", Protocol: "tcp", Port: "22", AllowDeny: "allow"},
+			{SrcIP: "db", DstIP: "10.1.0.0/16", Protocol: "tcp", Port: "22", AllowDeny: "allow"},
+		},
+	}
+	findings := AnalyzePolicies(firewall, nil)
+	assert.Equal(t, map[int][]PolicyFindingType{
+		1: {PolicyShadowed},
+		2: {PolicyBaseRedundant},
+	}, findingTypes(findings))
+}
+
+func TestAnalyzePoliciesBaseRedundantLaterRules(t *testing.T) {
+	firewall := &Firewall{
+		BaseAllowDeny: "allow",
+		PolicyList: []*Policy{
+			// without rule 0, port 80 would be denied by rule 1
+			{SrcIP: "10.0.0.0/8", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "80", AllowDeny: "allow"},
+			{SrcIP: "10.0.0.0/8", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "80-90", AllowDeny: "deny"},
+			// an earlier deny does not change what removing rule 2 does
+			{SrcIP: "10.0.0.0/8", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "100-200", AllowDeny: "allow"},
+		},
+	}
+	findings := AnalyzePolicies(firewall, nil)
+	assert.Equal(t, map[int][]PolicyFindingType{
+		2: {PolicyBaseRedundant},
+	}, findingTypes(findings))
+}
+
+func TestParsePortSet(t *testing.T) {
+	for _, value := range []string{"80", "80-90", "80:90", "", "all", "22,80-90"} {
+		_, err := parsePortSet(value)
+		assert.Nil(t, err, value)
+	}
+	for _, value := range []string{"80-", "-80", ":80", "80:", "80-90-100", "80--90", "+80", "80,"} {
+		_, err := parsePortSet(value)
+		assert.NotNil(t, err, value)
+	}
+}
+
+func TestAnalyzePoliciesUnresolvedTags(t *testing.T) {
+	tags := []*FirewallTag{
+		{Name: "web", CIDRList: []CIDRMember{{CIDRTag: "web-a", CIDR: "10.1.0.0/16"}}},
+		{Name: "new"},
+	}
+	firewall := &Firewall{
+		BaseAllowDeny: "deny",
+		PolicyList: []*Policy{
+			{SrcIP: "web", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "443", AllowDeny: "allow", LogEnable: "off"},
+			// an empty set would otherwise be a subset of rule 0
+			{SrcIP: "new", DstIP: "0.0.0.0/0", Protocol: "tcp", Port: "443", AllowDeny: "deny", LogEnable: "off"},
+			// an unknown tag would otherwise repeat the deny base policy
+			{SrcIP: "db", DstIP: "10.5.0.0/16", Protocol: "tcp", Port: "22", AllowDeny: "deny", LogEnable: "off"},
+			{SrcIP: "new", DstIP: "new", Protocol: "tcp", Port: "22", AllowDeny: "allow", LogEnable: "off"},
+		},
+	}
+	findings := AnalyzePolicies(firewall, tags)
+	assert.Equal(t, map[int][]PolicyFindingType{
+		1: {PolicyEmptyTag},
+		2: {PolicyUnknownTag},
+		3: {PolicyEmptyTag},
+	}, findingTypes(findings))
+	assert.Equal(t, "empty-tag: rule 1 references firewall tag \"new\", which has no members", findings[0].String())
+}
+
+func TestMergeIPRanges(t *testing.T) {
+	set, _, err := newTagResolver([]*FirewallTag{{Name: "t", CIDRList: []CIDRMember{
+		{CIDR: "10.0.1.0/24"}, {CIDR: "10.0.0.0/24"}, {CIDR: "10.0.0.128/25"}, {CIDR: "10.0.3.0/24"},
+	}}}).resolve("t")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(set.ranges))
+	wide, _, _ := newTagResolver(nil).resolve("10.0.0.0/23")
+	assert.Equal(t, true, wide.subsetOf(set))
+	assert.Equal(t, false, set.subsetOf(wide))
+	assert.Equal(t, true, set.overlaps(wide))
+}
@@ -0,0 +1,287 @@
+package goaviatrix
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// ErrPolicyChanged is returned by ApplyPolicyDiff when the gateway's policy no
+// longer matches the one the diff was computed against.
+var ErrPolicyChanged = errors.New("firewall policy changed since the diff was computed")
+
+// PolicyChangeType identifies the kind of a PolicyChange.
+type PolicyChangeType string
+
+const (
+	PolicyKeep   PolicyChangeType = "keep"
+	PolicyInsert PolicyChangeType = "insert"
+	PolicyRemove PolicyChangeType = "remove"
+	PolicyMove   PolicyChangeType = "move"
+)
+
+// PolicyChange is one rule in a PolicyDiff. OldIndex is the rule's position
+// in the current list and NewIndex its position in the desired list; either is
+// -1 when the rule is absent from that list.
+type PolicyChange struct {
+	Type     PolicyChangeType
+	Policy   *Policy
+	OldIndex int
+	NewIndex int
+}
+
+// PolicyDiff is the order-aware difference between a gateway's current and
+// desired rule lists. Changes lists every rule of both lists, with rules that
+// only exist in the current list placed where they were removed.
+type PolicyDiff struct {
+	GwName        string
+	Current       []*Policy
+	Desired       []*Policy
+	BaseAllowDeny string
+	BaseLogEnable string
+	Changes       []PolicyChange
+}
+
+// HasChanges reports whether applying the diff would change the gateway.
+func (d *PolicyDiff) HasChanges() bool {
+	if d.BaseAllowDeny != "" || d.BaseLogEnable != "" {
+		return true
+	}
+	for _, change := range d.Changes {
+		if change.Type != PolicyKeep {
+			return true
+		}
+	}
+	return false
+}
+
+// Count returns the number of changes of the given type.
+func (d *PolicyDiff) Count(changeType PolicyChangeType) int {
+	n := 0
+	for _, change := range d.Changes {
+		if change.Type == changeType {
+			n++
+		}
+	}
+	return n
+}
+
+// String renders the diff for change reviews: one line per rule in desired
+// order, prefixed with "+" (inserted), "-" (removed), "~" (moved) or " "
+// (unchanged).
+func (d *PolicyDiff) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "gateway %s: %d inserted, %d removed, %d moved\n", d.GwName, d.Count(PolicyInsert),
+		d.Count(PolicyRemove), d.Count(PolicyMove))
+	if d.BaseAllowDeny != "" {
+		fmt.Fprintf(&b, "~ base policy %s\n", d.BaseAllowDeny)
+	}
+	if d.BaseLogEnable != "" {
+		fmt.Fprintf(&b, "~ base policy log %s\n", d.BaseLogEnable)
+	}
+	for _, change := range d.Changes {
+		switch change.Type {
+		case PolicyKeep:
+			fmt.Fprintf(&b, "  %3d %s\n", change.NewIndex, policyKey(change.Policy))
+		case PolicyInsert:
+			fmt.Fprintf(&b, "+ %3d %s\n", change.NewIndex, policyKey(change.Policy))
+		case PolicyRemove:
+			fmt.Fprintf(&b, "- %3s %s\n", "", policyKey(change.Policy))
+		case PolicyMove:
+			fmt.Fprintf(&b, "~ %3d %s (was %d)\n", change.NewIndex, policyKey(change.Policy), change.OldIndex)
+		}
+	}
+	return b.String()
+}
+
+// DiffPolicies compares two ordered rule lists. Rules are matched on all of
+// their fields; the longest common subsequence is kept in place, matching
+// rules outside it are reported as moved and the rest as inserted or removed.
+func DiffPolicies(current, desired []*Policy) []PolicyChange {
+	n, m := len(current), len(desired)
+	currentKeys := make([]string, n)
+	for i, p := range current {
+		currentKeys[i] = policyKey(p)
+	}
+	desiredKeys := make([]string, m)
+	for j, p := range desired {
+		desiredKeys[j] = policyKey(p)
+	}
+
+	// lcs[i][j] is the LCS length of current[i:] and desired[j:]
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if currentKeys[i] == desiredKeys[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	// Walk the table to build the edit script.
+	changes := make([]PolicyChange, 0, n+m)
+	i, j := 0, 0
+	for i < n || j < m {
+		switch {
+		case i < n && j < m && currentKeys[i] == desiredKeys[j]:
+			changes = append(changes, PolicyChange{Type: PolicyKeep, Policy: desired[j], OldIndex: i, NewIndex: j})
+			i++
+			j++
+		case j < m && (i == n || lcs[i][j+1] >= lcs[i+1][j]):
+			changes = append(changes, PolicyChange{Type: PolicyInsert, Policy: desired[j], OldIndex: -1, NewIndex: j})
+			j++
+		default:
+			changes = append(changes, PolicyChange{Type: PolicyRemove, Policy: current[i], OldIndex: i, NewIndex: -1})
+			i++
+		}
+	}
+
+	// Pair removed and inserted copies of the same rule into moves.
+	removed := make(map[string][]int)
+	for k, change := range changes {
+		if change.Type == PolicyRemove {
+			key := currentKeys[change.OldIndex]
+			removed[key] = append(removed[key], k)
+		}
+	}
+	dropped := make(map[int]bool)
+	for k := range changes {
+		if changes[k].Type != PolicyInsert {
+			continue
+		}
+		key := desiredKeys[changes[k].NewIndex]
+		if len(removed[key]) == 0 {
+			continue
+		}
+		r := removed[key][0]
+		removed[key] = removed[key][1:]
+		changes[k].Type = PolicyMove
+		changes[k].OldIndex = changes[r].OldIndex
+		dropped[r] = true
+	}
+
+	result := make([]PolicyChange, 0, len(changes))
+	for k, change := range changes {
+		if !dropped[k] {
+			result = append(result, change)
+		}
+	}
+	return result
+}
+
+// PlanPolicy compares the gateway's current firewall with firewall and
+// returns the resulting diff without changing anything. An empty
+// BaseAllowDeny or BaseLogEnable in firewall leaves that setting untouched;
+// PolicyList is always treated as the complete desired rule list.
+func (c *Client) PlanPolicy(firewall *Firewall) (*PolicyDiff, error) {
+	current, err := c.GetPolicy(&Firewall{GwName: firewall.GwName})
+	if err != nil {
+		return nil, err
+	}
+	diff := &PolicyDiff{
+		GwName:  firewall.GwName,
+		Current: current.PolicyList,
+		Desired: firewall.PolicyList,
+		Changes: DiffPolicies(current.PolicyList, firewall.PolicyList),
+	}
+	if base := shortBasePolicy(firewall.BaseAllowDeny); base != "" && base != current.BaseAllowDeny {
+		diff.BaseAllowDeny = base
+	}
+	if firewall.BaseLogEnable != "" && firewall.BaseLogEnable != current.BaseLogEnable {
+		diff.BaseLogEnable = firewall.BaseLogEnable
+	}
+	return diff, nil
+}
+
+// ApplyPolicyDiff applies a diff produced by PlanPolicy. The gateway's rules
+// are read again first and ErrPolicyChanged is returned if they no longer
+// match diff.Current, so a reviewed plan is never applied over someone else's
+// edit. The rules are updated before the base policy: a base policy tightened
+// from allow to deny only takes effect once the allow rules it relies on are
+// in place, and one loosened to allow once its deny rules are. This client has
+// no per-rule calls, so the desired rules are sent in one UpdatePolicy call,
+// which form-encodes them in the request body.
+func (c *Client) ApplyPolicyDiff(diff *PolicyDiff) error {
+	if !diff.HasChanges() {
+		return nil
+	}
+	current, err := c.GetPolicy(&Firewall{GwName: diff.GwName})
+	if err != nil {
+		return err
+	}
+	if !policiesEqual(current.PolicyList, diff.Current) {
+		return ErrPolicyChanged
+	}
+
+	for _, change := range diff.Changes {
+		if change.Type != PolicyKeep {
+			desired := diff.Desired
+			if desired == nil {
+				desired = []*Policy{}
+			}
+			log.Printf("[INFO] Updating %d rules on gateway %s", len(desired), diff.GwName)
+			if err := c.UpdatePolicy(&Firewall{GwName: diff.GwName, PolicyList: desired}); err != nil {
+				return err
+			}
+			break
+		}
+	}
+	if diff.BaseAllowDeny != "" || diff.BaseLogEnable != "" {
+		base := &Firewall{
+			GwName:        diff.GwName,
+			BaseAllowDeny: firstNonEmpty(diff.BaseAllowDeny, current.BaseAllowDeny),
+			BaseLogEnable: firstNonEmpty(diff.BaseLogEnable, current.BaseLogEnable),
+		}
+		base.BaseAllowDeny += "-all"
+		return c.SetBasePolicy(base)
+	}
+	return nil
+}
+
+// ApplyPolicy plans and applies firewall in one step and returns the diff that
+// was applied.
+func (c *Client) ApplyPolicy(firewall *Firewall) (*PolicyDiff, error) {
+	diff, err := c.PlanPolicy(firewall)
+	if err != nil {
+		return nil, err
+	}
+	return diff, c.ApplyPolicyDiff(diff)
+}
+
+// shortBasePolicy maps the "allow-all"/"deny-all" base policy values taken by
+// SetBasePolicy to the "allow"/"deny" form returned by GetPolicy.
+func shortBasePolicy(base string) string {
+	return strings.TrimSuffix(base, "-all")
+}
+
+func policiesEqual(a, b []*Policy) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if policyKey(a[i]) != policyKey(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// policyKey identifies a rule by all of its fields. An empty LogEnable is
+// keyed as "off", so that the two compare equal.
+func policyKey(p *Policy) string {
+	logEnable := strings.ToLower(strings.TrimSpace(p.LogEnable))
+	if logEnable == "" {
+		logEnable = "off"
+	}
+	return fmt.Sprintf("%s %s %s -> %s port %s log %s", strings.TrimSpace(p.AllowDeny),
+		strings.TrimSpace(p.Protocol), strings.TrimSpace(p.SrcIP), strings.TrimSpace(p.DstIP),
+		strings.TrimSpace(p.Port), logEnable)
+}
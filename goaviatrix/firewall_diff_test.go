@@ -0,0 +1,138 @@
+package goaviatrix
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func testPolicy(src, dst, port string) *Policy {
+	return &Policy{SrcIP: src, DstIP: dst, Protocol: "tcp", Port: port, AllowDeny: "allow", LogEnable: "off"}
+}
+
+func changeTypes(changes []PolicyChange) []PolicyChangeType {
+	types := make([]PolicyChangeType, 0, len(changes))
+	for _, change := range changes {
+		types = append(types, change.Type)
+	}
+	return types
+}
+
+func TestDiffPoliciesInsertRemove(t *testing.T) {
+	a := testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")
+	b := testPolicy("10.0.0.0/16", "10.1.0.0/16", "80")
+	c := testPolicy("10.0.0.0/16", "10.2.0.0/16", "22")
+	d := testPolicy("10.0.0.0/16", "10.3.0.0/16", "53")
+
+	changes := DiffPolicies([]*Policy{a, b, c}, []*Policy{a, d, c})
+	assert.Equal(t, []PolicyChangeType{PolicyKeep, PolicyInsert, PolicyRemove, PolicyKeep}, changeTypes(changes))
+	assert.Equal(t, 1, changes[1].NewIndex)
+	assert.Equal(t, 1, changes[2].OldIndex)
+	assert.Equal(t, 2, changes[3].NewIndex)
+	assert.Equal(t, 2, changes[3].OldIndex)
+}
+
+func TestDiffPoliciesMove(t *testing.T) {
+	a := testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")
+	b := testPolicy("10.0.0.0/16", "10.1.0.0/16", "80")
+	c := testPolicy("10.0.0.0/16", "10.2.0.0/16", "22")
+
+	changes := DiffPolicies([]*Policy{a, b, c}, []*Policy{c, a, b})
+	assert.Equal(t, []PolicyChangeType{PolicyMove, PolicyKeep, PolicyKeep}, changeTypes(changes))
+	assert.Equal(t, 2, changes[0].OldIndex)
+	assert.Equal(t, 0, changes[0].NewIndex)
+}
+
+func TestDiffPoliciesDuplicates(t *testing.T) {
+	a := testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")
+	b := testPolicy("10.0.0.0/16", "10.1.0.0/16", "80")
+
+	changes := DiffPolicies([]*Policy{a, a, b}, []*Policy{a, b})
+	assert.Equal(t, []PolicyChangeType{PolicyKeep, PolicyRemove, PolicyKeep}, changeTypes(changes))
+}
+
+func TestDiffPoliciesLogDefault(t *testing.T) {
+	a := testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")
+	b := *a
+	b.LogEnable = ""
+
+	changes := DiffPolicies([]*Policy{a}, []*Policy{&b})
+	assert.Equal(t, []PolicyChangeType{PolicyKeep}, changeTypes(changes))
+}
+
+func TestPolicyDiffString(t *testing.T) {
+	a := testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")
+	b := testPolicy("10.0.0.0/16", "10.1.0.0/16", "80")
+	diff := &PolicyDiff{
+		GwName:        "gw1",
+		BaseAllowDeny: "deny",
+		Changes:       DiffPolicies([]*Policy{a}, []*Policy{b, a}),
+	}
+	assert.Equal(t, true, diff.HasChanges())
+	assert.Equal(t, "gateway gw1: 1 inserted, 0 removed, 0 moved\n"+
+		"~ base policy deny\n"+
+		"+   0 allow tcp 10.0.0.0/16 -> 10.1.0.0/16 port 80 log off\n"+
+		"    1 allow tcp 10.0.0.0/16 -> 10.1.0.0/16 port 443 log off\n", diff.String())
+}
+
+func TestApplyPolicyOrder(t *testing.T) {
+	calls := make([]string, 0)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "vpc_access_policy":
+			w.Write([]byte(`{"return": true, "results": {"vpc_name": "gw1", "base_policy": "allow-all",
+				"base_policy_log_enable": "off", "security_rules": []}}`))
+		default:
+			calls = append(calls, r.Form.Get("action")+" "+r.Form.Get("base_policy"))
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient),
+		BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	diff, err := client.ApplyPolicy(&Firewall{
+		GwName:        "gw1",
+		BaseAllowDeny: "deny-all",
+		PolicyList:    []*Policy{testPolicy("10.0.0.0/16", "10.1.0.0/16", "443")},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "deny", diff.BaseAllowDeny)
+	assert.Equal(t, []string{"update_access_policy ", "set_vpc_base_policy deny-all"}, calls)
+}
+
+func TestUpdatePolicyBody(t *testing.T) {
+	var policies []*Policy
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "update_access_policy":
+			assert.Equal(t, "POST", r.Method)
+			assert.Equal(t, "", r.URL.RawQuery)
+			assert.Equal(t, "gw1", r.PostForm.Get("vpc_name"))
+			assert.Nil(t, json.Unmarshal([]byte(r.PostForm.Get("new_policy")), &policies))
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient),
+		BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	rule := testPolicy("10.0.0.0/16", "10.1.0.0/16", "80,443")
+	rule.SrcIP = "a&b=c #1"
+	assert.Nil(t, client.UpdatePolicy(&Firewall{GwName: "gw1", PolicyList: []*Policy{rule}}))
+	assert.Equal(t, []*Policy{rule}, policies)
+}
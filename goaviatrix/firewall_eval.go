@@ -0,0 +1,146 @@
+package goaviatrix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+)
+
+// Flow describes a connection to test against a gateway's firewall. Port is
+// ignored for icmp.
+type Flow struct {
+	SrcIP    string
+	DstIP    string
+	Protocol string
+	Port     int
+}
+
+// FlowVerdict is the result of evaluating a Flow. Index is the position of
+// the matching rule in Firewall.PolicyList, or -1 when the flow fell through
+// to the base policy, in which case Policy is nil.
+type FlowVerdict struct {
+	Allowed bool
+	Log     bool
+	Index   int
+	Policy  *Policy
+}
+
+func (v *FlowVerdict) String() string {
+	action := "denied"
+	if v.Allowed {
+		action = "allowed"
+	}
+	if v.Index < 0 {
+		return action + " by base policy"
+	}
+	return fmt.Sprintf("%s by rule %d (%s)", action, v.Index, FormatPolicyRule(v.Policy))
+}
+
+// EvaluateFlow runs flow through the firewall's rules in order and returns the
+// first match, or the base policy if no rule matches. Rules referencing
+// firewall tags are resolved against tags; a tag missing from tags or a rule
+// that cannot be parsed is an error, since the outcome would be a guess.
+func EvaluateFlow(firewall *Firewall, tags []*FirewallTag, flow *Flow) (*FlowVerdict, error) {
+	src, err := parseFlowIP(flow.SrcIP)
+	if err != nil {
+		return nil, err
+	}
+	dst, err := parseFlowIP(flow.DstIP)
+	if err != nil {
+		return nil, err
+	}
+	protocol := strings.ToLower(flow.Protocol)
+	if protocol == "" {
+		return nil, errors.New("flow protocol is required")
+	}
+	if protocol != "icmp" && (flow.Port < 0 || flow.Port > 65535) {
+		return nil, fmt.Errorf("invalid flow port %d", flow.Port)
+	}
+
+	if tags == nil {
+		tags = []*FirewallTag{}
+	}
+	resolver := newTagResolver(tags)
+	for i, p := range firewall.PolicyList {
+		rule, unknown, _, err := parsePolicyMatch(p, resolver)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %v", i, err)
+		}
+		if len(unknown) != 0 {
+			return nil, fmt.Errorf("rule %d references unknown firewall tag %q", i, unknown[0])
+		}
+		if rule.matches(src, dst, protocol, flow.Port) {
+			return &FlowVerdict{
+				Allowed: rule.allow,
+				Log:     rule.log,
+				Index:   i,
+				Policy:  p,
+			}, nil
+		}
+	}
+
+	base := shortBasePolicy(strings.ToLower(firewall.BaseAllowDeny))
+	if base != "allow" && base != "deny" {
+		return nil, fmt.Errorf("unknown base policy %q", firewall.BaseAllowDeny)
+	}
+	return &FlowVerdict{
+		Allowed: base == "allow",
+		Log:     firewall.BaseLogEnable == "on",
+		Index:   -1,
+	}, nil
+}
+
+// SimulateFlow loads the firewall of gwName together with every firewall tag
+// its rules reference and evaluates flow against it with EvaluateFlow.
+func (c *Client) SimulateFlow(gwName string, flow *Flow) (*FlowVerdict, error) {
+	firewall, err := c.GetPolicy(&Firewall{GwName: gwName})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make([]*FirewallTag, 0)
+	seen := make(map[string]bool)
+	for _, p := range firewall.PolicyList {
+		for _, name := range []string{strings.TrimSpace(p.SrcIP), strings.TrimSpace(p.DstIP)} {
+			if _, isAddr, _ := parseIPv4Range(name); isAddr || name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+			tag, err := c.GetFirewallTag(&FirewallTag{Name: name})
+			if err != nil {
+				return nil, fmt.Errorf("firewall tag %s: %v", name, err)
+			}
+			tag.Name = name
+			tags = append(tags, tag)
+		}
+	}
+	return EvaluateFlow(firewall, tags, flow)
+}
+
+func (r *policyMatch) matches(src, dst uint32, protocol string, port int) bool {
+	if r.protocol != "" && r.protocol != protocol {
+		return false
+	}
+	if protocol != "icmp" && !r.ports.contains(port) {
+		return false
+	}
+	return r.src.contains(src) && r.dst.contains(dst)
+}
+
+func (a addrSet) contains(ip uint32) bool {
+	return a.containsRange(ipRange{ip, ip})
+}
+
+func (p portSet) contains(port int) bool {
+	return addrSet{ranges: p}.containsRange(ipRange{uint32(port), uint32(port)})
+}
+
+func parseFlowIP(value string) (uint32, error) {
+	ip := net.ParseIP(strings.TrimSpace(value)).To4()
+	if ip == nil {
+		return 0, fmt.Errorf("invalid IPv4 address %q", value)
+	}
+	return binary.BigEndian.Uint32(ip), nil
+}
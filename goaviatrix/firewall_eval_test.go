@@ -0,0 +1,111 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	vpcAccessPolicy = `{
+		"return": true,
+		"results": {
+			"vpc_name": "gw1",
+			"base_policy": "deny-all",
+			"base_policy_log_enable": "off",
+			"security_rules": [
+				{"s_ip": "10.1.2.3", "d_ip": "db", "protocol": "tcp", "port": "5432", "deny_allow": "deny", "log_enable": "on"},
+				{"s_ip": "10.0.0.0/8", "d_ip": "db", "protocol": "tcp", "port": "5432", "deny_allow": "allow", "log_enable": "off"},
+				{"s_ip": "10.0.0.0/8", "d_ip": "0.0.0.0/0", "protocol": "icmp", "port": "", "deny_allow": "allow", "log_enable": "off"}
+			]
+		}
+	  }`
+	listPolicyMembersDB = `{
+		"return": true,
+		"results": {
+			"members": [
+				{"name": "db1", "cidr": "10.9.0.0/24"},
+				{"name": "db2", "cidr": "10.9.1.5/32"}
+			]
+		}
+	  }`
+)
+
+func TestEvaluateFlow(t *testing.T) {
+	tags := []*FirewallTag{{Name: "web", CIDRList: []CIDRMember{{CIDR: "10.1.0.0/16"}}}}
+	firewall := &Firewall{
+		BaseAllowDeny: "allow",
+		PolicyList: []*Policy{
+			{SrcIP: "web", DstIP: "10.9.0.0/16", Protocol: "tcp", Port: "443", AllowDeny: "allow", LogEnable: "on"},
+			{SrcIP: "0.0.0.0/0", DstIP: "10.9.0.0/16", Protocol: "all", Port: "", AllowDeny: "deny", LogEnable: "off"},
+		},
+	}
+	tests := []struct {
+		name  string
+		flow  Flow
+		index int
+		allow bool
+	}{
+		{"tag match", Flow{SrcIP: "10.1.2.3", DstIP: "10.9.0.5", Protocol: "tcp", Port: 443}, 0, true},
+		{"wrong port", Flow{SrcIP: "10.1.2.3", DstIP: "10.9.0.5", Protocol: "tcp", Port: 80}, 1, false},
+		{"outside tag", Flow{SrcIP: "10.2.0.1", DstIP: "10.9.0.5", Protocol: "TCP", Port: 443}, 1, false},
+		{"base policy", Flow{SrcIP: "10.1.2.3", DstIP: "8.8.8.8", Protocol: "udp", Port: 53}, -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := EvaluateFlow(firewall, tags, &tt.flow)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.index, verdict.Index)
+			assert.Equal(t, tt.allow, verdict.Allowed)
+		})
+	}
+
+	_, err := EvaluateFlow(firewall, nil, &Flow{SrcIP: "10.1.2.3", DstIP: "10.9.0.5", Protocol: "tcp", Port: 443})
+	assert.NotNil(t, err)
+	_, err = EvaluateFlow(firewall, tags, &Flow{SrcIP: "10.1.2", DstIP: "10.9.0.5", Protocol: "tcp", Port: 443})
+	assert.NotNil(t, err)
+}
+
+func TestSimulateFlow(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "vpc_access_policy":
+			assert.Equal(t, "gw1", r.Form.Get("vpc_name"))
+			w.Write([]byte(vpcAccessPolicy))
+		case "list_policy_members":
+			assert.Equal(t, "db", r.Form.Get("tag_name"))
+			w.Write([]byte(listPolicyMembersDB))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	if err != nil {
+		fmt.Println("unable to create client")
+	}
+	assert.Nil(t, err)
+
+	verdict, err := client.SimulateFlow("gw1", &Flow{SrcIP: "10.1.2.3", DstIP: "10.9.1.5", Protocol: "tcp", Port: 5432})
+	assert.Nil(t, err)
+	assert.Equal(t, false, verdict.Allowed)
+	assert.Equal(t, true, verdict.Log)
+	assert.Equal(t, "denied by rule 0 (deny tcp from 10.1.2.3 to db port 5432 log)", verdict.String())
+
+	verdict, err = client.SimulateFlow("gw1", &Flow{SrcIP: "10.4.0.1", DstIP: "10.9.0.200", Protocol: "tcp", Port: 5432})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, verdict.Index)
+
+	verdict, err = client.SimulateFlow("gw1", &Flow{SrcIP: "10.4.0.1", DstIP: "1.1.1.1", Protocol: "icmp"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, verdict.Index)
+
+	verdict, err = client.SimulateFlow("gw1", &Flow{SrcIP: "192.168.0.1", DstIP: "10.9.1.5", Protocol: "tcp", Port: 5432})
+	assert.Nil(t, err)
+	assert.Equal(t, "denied by base policy", verdict.String())
+}
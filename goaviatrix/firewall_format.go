@@ -0,0 +1,255 @@
+package goaviatrix
+
+import (
+	"bufio"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// policyCSVHeader lists the CSV columns used by WritePoliciesCSV, named after
+// the controller's rule fields.
+var policyCSVHeader = []string{"s_ip", "d_ip", "protocol", "port", "deny_allow", "log_enable"}
+
+// WritePoliciesCSV writes policies as CSV with a header row.
+func WritePoliciesCSV(w io.Writer, policies []*Policy) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(policyCSVHeader); err != nil {
+		return err
+	}
+	for _, p := range policies {
+		record := []string{p.SrcIP, p.DstIP, p.Protocol, p.Port, p.AllowDeny, p.LogEnable}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// ReadPoliciesCSV reads rules written by WritePoliciesCSV or authored in a
+// spreadsheet. The header row is required but columns may appear in any
+// order; log_enable is optional and defaults to "off".
+func ReadPoliciesCSV(r io.Reader) ([]*Policy, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, errors.New("missing CSV header")
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range policyCSVHeader[:5] {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing CSV column %q", name)
+		}
+	}
+	field := func(record []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	policies := make([]*Policy, 0)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := reader.FieldPos(0)
+		p := &Policy{
+			SrcIP:     field(record, "s_ip"),
+			DstIP:     field(record, "d_ip"),
+			Protocol:  field(record, "protocol"),
+			Port:      field(record, "port"),
+			AllowDeny: field(record, "deny_allow"),
+			LogEnable: field(record, "log_enable"),
+		}
+		if err := normalizePolicy(p); err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		policies = append(policies, p)
+	}
+	return policies, nil
+}
+
+// FormatPolicyRule renders a rule in the line syntax read by ParsePolicyRule:
+//
+//	allow tcp from 10.0.0.0/16 to 10.1.0.0/16 port 443 log
+//
+// The port clause is left out when the rule has no port and "log" is only
+// written when logging is on.
+func FormatPolicyRule(p *Policy) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %s from %s to %s", quoteRuleToken(p.AllowDeny), quoteRuleToken(p.Protocol),
+		quoteRuleToken(p.SrcIP), quoteRuleToken(p.DstIP))
+	if p.Port != "" {
+		fmt.Fprintf(&b, " port %s", quoteRuleToken(p.Port))
+	}
+	if p.LogEnable == "on" {
+		b.WriteString(" log")
+	}
+	return b.String()
+}
+
+// ParsePolicyRule parses one rule in the syntax written by FormatPolicyRule.
+// Values containing spaces may be double-quoted.
+func ParsePolicyRule(line string) (*Policy, error) {
+	tokens, err := splitRuleTokens(line)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) < 6 || tokens[2] != "from" || tokens[4] != "to" {
+		return nil, fmt.Errorf("expected \"<allow|deny> <protocol> from <source> to <destination>\", got %q", line)
+	}
+	p := &Policy{
+		AllowDeny: tokens[0],
+		Protocol:  tokens[1],
+		SrcIP:     tokens[3],
+		DstIP:     tokens[5],
+		LogEnable: "off",
+	}
+	rest := tokens[6:]
+	if len(rest) >= 2 && rest[0] == "port" {
+		p.Port = rest[1]
+		rest = rest[2:]
+	}
+	if len(rest) == 1 && rest[0] == "log" {
+		p.LogEnable = "on"
+		rest = rest[1:]
+	}
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("unexpected %q", strings.Join(rest, " "))
+	}
+	if err := normalizePolicy(p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// WriteFirewallRules writes a gateway's base policy and rules in the line
+// syntax, one rule per line, for review in version control.
+func WriteFirewallRules(w io.Writer, firewall *Firewall) error {
+	bw := bufio.NewWriter(w)
+	if firewall.GwName != "" {
+		fmt.Fprintf(bw, "# gateway %s\n", firewall.GwName)
+	}
+	if firewall.BaseAllowDeny != "" {
+		fmt.Fprintf(bw, "base %s", shortBasePolicy(firewall.BaseAllowDeny))
+		if firewall.BaseLogEnable == "on" {
+			bw.WriteString(" log")
+		}
+		bw.WriteString("\n")
+	}
+	for _, p := range firewall.PolicyList {
+		fmt.Fprintln(bw, FormatPolicyRule(p))
+	}
+	return bw.Flush()
+}
+
+// ReadFirewallRules reads rules written by WriteFirewallRules. Blank lines and
+// lines starting with "#" are ignored; an optional "base allow|deny [log]"
+// line sets the base policy. The returned Firewall has no GwName, so the
+// caller chooses the gateway it is applied to.
+func ReadFirewallRules(r io.Reader) (*Firewall, error) {
+	firewall := &Firewall{PolicyList: make([]*Policy, 0)}
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if fields := strings.Fields(text); fields[0] == "base" {
+			if len(fields) < 2 || len(fields) > 3 || (fields[1] != "allow" && fields[1] != "deny") ||
+				(len(fields) == 3 && fields[2] != "log") {
+				return nil, fmt.Errorf("line %d: expected \"base <allow|deny> [log]\"", line)
+			}
+			firewall.BaseAllowDeny = fields[1]
+			firewall.BaseLogEnable = "off"
+			if len(fields) == 3 {
+				firewall.BaseLogEnable = "on"
+			}
+			continue
+		}
+		p, err := ParsePolicyRule(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", line, err)
+		}
+		firewall.PolicyList = append(firewall.PolicyList, p)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return firewall, nil
+}
+
+// normalizePolicy checks the fields every rule needs and lowercases the
+// keyword fields.
+func normalizePolicy(p *Policy) error {
+	p.AllowDeny = strings.ToLower(p.AllowDeny)
+	p.Protocol = strings.ToLower(p.Protocol)
+	p.LogEnable = strings.ToLower(p.LogEnable)
+	if p.AllowDeny != "allow" && p.AllowDeny != "deny" {
+		return fmt.Errorf("action must be allow or deny, got %q", p.AllowDeny)
+	}
+	if p.Protocol == "" {
+		return errors.New("protocol is required")
+	}
+	if p.SrcIP == "" || p.DstIP == "" {
+		return errors.New("source and destination are required")
+	}
+	switch p.LogEnable {
+	case "":
+		p.LogEnable = "off"
+	case "on", "off":
+	default:
+		return fmt.Errorf("log_enable must be on or off, got %q", p.LogEnable)
+	}
+	return nil
+}
+
+func quoteRuleToken(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\"") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+func splitRuleTokens(line string) ([]string, error) {
+	tokens := make([]string, 0)
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return tokens, nil
+		}
+		if line[0] == '"' {
+			quoted, err := strconv.QuotedPrefix(line)
+			if err != nil {
+				return nil, fmt.Errorf("unterminated quote in %q", line)
+			}
+			token, _ := strconv.Unquote(quoted)
+			tokens = append(tokens, token)
+			line = line[len(quoted):]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			end = len(line)
+		}
+		tokens = append(tokens, line[:end])
+		line = line[end:]
+	}
+}
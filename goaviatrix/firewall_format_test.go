@@ -0,0 +1,87 @@
+package goaviatrix
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var formatTestPolicies = []*Policy{
+	{SrcIP: "10.0.0.0/16", DstIP: "10.1.0.0/16", Protocol: "tcp", Port: "443", AllowDeny: "allow", LogEnable: "on"},
+	{SrcIP: "web servers", DstIP: "0.0.0.0/0", Protocol: "all", Port: "", AllowDeny: "deny", LogEnable: "off"},
+}
+
+func TestPoliciesCSVRoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	err := WritePoliciesCSV(&buf, formatTestPolicies)
+	assert.Nil(t, err)
+	assert.Equal(t, "s_ip,d_ip,protocol,port,deny_allow,log_enable\n"+
+		"10.0.0.0/16,10.1.0.0/16,tcp,443,allow,on\n"+
+		"web servers,0.0.0.0/0,all,,deny,off\n", buf.String())
+
+	policies, err := ReadPoliciesCSV(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, formatTestPolicies, policies)
+}
+
+func TestReadPoliciesCSVSpreadsheet(t *testing.T) {
+	input := "Deny_Allow, Protocol, S_IP, D_IP, Port\n" +
+		"Allow, TCP, 10.0.0.0/16, 10.1.0.0/16, 22\n"
+	policies, err := ReadPoliciesCSV(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, []*Policy{
+		{SrcIP: "10.0.0.0/16", DstIP: "10.1.0.0/16", Protocol: "tcp", Port: "22", AllowDeny: "allow", LogEnable: "off"},
+	}, policies)
+
+	_, err = ReadPoliciesCSV(strings.NewReader("s_ip,d_ip,protocol,port\n"))
+	assert.NotNil(t, err)
+
+	input = "s_ip,d_ip,protocol,port,deny_allow\n" +
+		"10.0.0.0/16,10.1.0.0/16,tcp,22,allow\n" +
+		"10.0.0.0/16,10.1.0.0/16,tcp,22,permit\n"
+	_, err = ReadPoliciesCSV(strings.NewReader(input))
+	assert.NotNil(t, err)
+	assert.Equal(t, true, strings.HasPrefix(err.Error(), "line 3:"))
+}
+
+func TestFirewallRulesRoundTrip(t *testing.T) {
+	firewall := &Firewall{
+		GwName:        "gw1",
+		BaseAllowDeny: "deny",
+		BaseLogEnable: "on",
+		PolicyList:    formatTestPolicies,
+	}
+	var buf bytes.Buffer
+	err := WriteFirewallRules(&buf, firewall)
+	assert.Nil(t, err)
+	assert.Equal(t, "# gateway gw1\n"+
+		"base deny log\n"+
+		"allow tcp from 10.0.0.0/16 to 10.1.0.0/16 port 443 log\n"+
+		"deny all from \"web servers\" to 0.0.0.0/0\n", buf.String())
+
+	parsed, err := ReadFirewallRules(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "", parsed.GwName)
+	assert.Equal(t, "deny", parsed.BaseAllowDeny)
+	assert.Equal(t, "on", parsed.BaseLogEnable)
+	assert.Equal(t, formatTestPolicies, parsed.PolicyList)
+}
+
+func TestParsePolicyRuleErrors(t *testing.T) {
+	for _, line := range []string{
+		"allow tcp 10.0.0.0/16 to 10.1.0.0/16",
+		"permit tcp from 10.0.0.0/16 to 10.1.0.0/16",
+		"allow tcp from 10.0.0.0/16 to 10.1.0.0/16 port",
+		"allow tcp from 10.0.0.0/16 to 10.1.0.0/16 log port 22",
+		"allow tcp from \"web to 10.1.0.0/16",
+	} {
+		_, err := ParsePolicyRule(line)
+		assert.NotNil(t, err, line)
+	}
+
+	_, err := ReadFirewallRules(strings.NewReader("# comment\n\nbase maybe\n"))
+	assert.NotNil(t, err)
+	assert.Equal(t, true, strings.HasPrefix(err.Error(), "line 3:"))
+}
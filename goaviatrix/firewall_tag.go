@@ -105,7 +105,109 @@ func (c *Client) GetFirewallTag(firewall_tag *FirewallTag) (*FirewallTag, error)
 	return &data.Results, nil
 }
 
-func (c *Client) DeleteFirewallTag(firewall_tag *FirewallTag) error {
+// FirewallTagReference is a firewall rule that names a firewall tag as its
+// source (s_ip) or destination (d_ip).
+type FirewallTagReference struct {
+	GwName string
+	Index  int
+	Field  string
+}
+
+// FirewallTagInUseError is returned by DeleteFirewallTag when RefuseIfInUse
+// is given and gateway policies still reference the tag.
+type FirewallTagInUseError struct {
+	Name       string
+	References []FirewallTagReference
+}
+
+func (e *FirewallTagInUseError) Error() string {
+	refs := make([]string, 0, len(e.References))
+	for _, ref := range e.References {
+		refs = append(refs, fmt.Sprintf("%s rule %d (%s)", ref.GwName, ref.Index, ref.Field))
+	}
+	return fmt.Sprintf("firewall tag %s is still referenced by %s", e.Name, strings.Join(refs, ", "))
+}
+
+// FirewallTagDeleteOption is a functional option for DeleteFirewallTag.
+type FirewallTagDeleteOption func(*firewallTagDeleteConfig)
+
+type firewallTagDeleteConfig struct {
+	refuseIfInUse bool
+}
+
+// RefuseIfInUse makes DeleteFirewallTag scan gateway policies first and fail
+// with a *FirewallTagInUseError instead of deleting a tag that is referenced.
+func RefuseIfInUse() FirewallTagDeleteOption {
+	return func(cfg *firewallTagDeleteConfig) {
+		cfg.refuseIfInUse = true
+	}
+}
+
+// ListFirewallTags returns the names of all firewall tags. Use
+// GetFirewallTag to read a tag's members.
+func (c *Client) ListFirewallTags() ([]*FirewallTag, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_policy_tags", c.CID)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data ResultListResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		return nil, errors.New(data.Reason)
+	}
+	tags := make([]*FirewallTag, 0, len(data.Results))
+	for _, name := range data.Results {
+		tags = append(tags, &FirewallTag{Name: name})
+	}
+	return tags, nil
+}
+
+// FindFirewallTagReferences scans the firewall policy of every gateway and
+// returns the rules that use the named tag. Gateways the controller reports
+// as having no firewall policy are skipped; any other failure to read a
+// policy is returned, so that no reference is missed.
+func (c *Client) FindFirewallTagReferences(name string) ([]FirewallTagReference, error) {
+	gateways, err := c.ListGateways()
+	if err != nil {
+		return nil, err
+	}
+	refs := make([]FirewallTagReference, 0)
+	for _, gw := range gateways {
+		firewall, err := c.getPolicy(&Firewall{GwName: gw.GwName})
+		if noPolicy(err) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("reading firewall policy of %s: %v", gw.GwName, err)
+		}
+		for i, p := range firewall.PolicyList {
+			if strings.TrimSpace(p.SrcIP) == name {
+				refs = append(refs, FirewallTagReference{GwName: gw.GwName, Index: i, Field: "s_ip"})
+			}
+			if strings.TrimSpace(p.DstIP) == name {
+				refs = append(refs, FirewallTagReference{GwName: gw.GwName, Index: i, Field: "d_ip"})
+			}
+		}
+	}
+	return refs, nil
+}
+
+func (c *Client) DeleteFirewallTag(firewall_tag *FirewallTag, opts ...FirewallTagDeleteOption) error {
+	cfg := &firewallTagDeleteConfig{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.refuseIfInUse {
+		refs, err := c.FindFirewallTagReferences(firewall_tag.Name)
+		if err != nil {
+			return err
+		}
+		if len(refs) != 0 {
+			return &FirewallTagInUseError{Name: firewall_tag.Name, References: refs}
+		}
+	}
 	firewall_tag.CID = c.CID
 	firewall_tag.Action = "del_policy_tag"
 	log.Printf("[INFO] Deleting Firewall Tag: %#v", firewall_tag)
@@ -0,0 +1,240 @@
+package goaviatrix
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"net"
+	"strings"
+)
+
+// ErrFirewallTagChanged is returned by AddFirewallTagMembers and
+// RemoveFirewallTagMembers when the tag's members changed while the update was
+// being computed.
+var ErrFirewallTagChanged = errors.New("firewall tag members changed since they were read")
+
+// AddFirewallTagMembers adds the members in firewall_tag.CIDRList to the
+// tag's current member list. Members whose CIDR is already present are
+// skipped. With collapse set, overlapping and adjacent CIDRs of the merged
+// list are combined into the fewest covering CIDRs. The updated tag is
+// returned.
+func (c *Client) AddFirewallTagMembers(firewall_tag *FirewallTag, collapse bool) (*FirewallTag, error) {
+	additions, err := normalizeCIDRMembers(firewall_tag.CIDRList)
+	if err != nil {
+		return nil, err
+	}
+	current, err := c.GetFirewallTag(&FirewallTag{Name: firewall_tag.Name})
+	if err != nil {
+		return nil, err
+	}
+	members, err := normalizeCIDRMembers(current.CIDRList)
+	if err != nil {
+		return nil, err
+	}
+
+	present := make(map[string]bool)
+	for _, member := range members {
+		present[member.CIDR] = true
+	}
+	for _, member := range additions {
+		if !present[member.CIDR] {
+			members = append(members, member)
+			present[member.CIDR] = true
+		}
+	}
+	if collapse {
+		members = CollapseCIDRMembers(members)
+	}
+	return c.replaceFirewallTagMembers(firewall_tag.Name, current.CIDRList, members)
+}
+
+// RemoveFirewallTagMembers removes the addresses in firewall_tag.CIDRList
+// from the tag. A removal applies to every member whose CIDR overlaps it, or
+// only to those with the same name when one is given. Members covered by a
+// removal are dropped, and members partly covered are replaced by the CIDRs
+// of what is left under the same name. Removals matching nothing are ignored.
+// With collapse set, the remaining members are collapsed as in
+// AddFirewallTagMembers. The updated tag is returned.
+func (c *Client) RemoveFirewallTagMembers(firewall_tag *FirewallTag, collapse bool) (*FirewallTag, error) {
+	removals := make([]CIDRMember, 0, len(firewall_tag.CIDRList))
+	for _, member := range firewall_tag.CIDRList {
+		cidr, err := canonicalCIDR(member.CIDR)
+		if err != nil {
+			return nil, err
+		}
+		removals = append(removals, CIDRMember{CIDRTag: member.CIDRTag, CIDR: cidr})
+	}
+	current, err := c.GetFirewallTag(&FirewallTag{Name: firewall_tag.Name})
+	if err != nil {
+		return nil, err
+	}
+	members, err := normalizeCIDRMembers(current.CIDRList)
+	if err != nil {
+		return nil, err
+	}
+
+	remaining := make([]CIDRMember, 0, len(members))
+	for _, member := range members {
+		r, _, _ := parseIPv4Range(member.CIDR)
+		cuts := make([]ipRange, 0)
+		for _, removal := range removals {
+			if removal.CIDRTag == "" || removal.CIDRTag == member.CIDRTag {
+				cut, _, _ := parseIPv4Range(removal.CIDR)
+				cuts = append(cuts, cut)
+			}
+		}
+		for _, left := range subtractIPRanges(r, cuts) {
+			for _, block := range rangeToCIDRs(left) {
+				remaining = append(remaining, CIDRMember{CIDRTag: member.CIDRTag, CIDR: formatIPRangeCIDR(block)})
+			}
+		}
+	}
+	if collapse {
+		remaining = CollapseCIDRMembers(remaining)
+	}
+	return c.replaceFirewallTagMembers(firewall_tag.Name, current.CIDRList, remaining)
+}
+
+// replaceFirewallTagMembers sets the members of tag name to members. The tag
+// is read again first and ErrFirewallTagChanged returned if its members no
+// longer match current, so a concurrent edit is not overwritten.
+func (c *Client) replaceFirewallTagMembers(name string, current, members []CIDRMember) (*FirewallTag, error) {
+	latest, err := c.GetFirewallTag(&FirewallTag{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if !cidrMembersEqual(latest.CIDRList, current) {
+		return nil, ErrFirewallTagChanged
+	}
+	updated := &FirewallTag{
+		Name:     name,
+		CIDRList: members,
+	}
+	if err := c.UpdateFirewallTag(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+func cidrMembersEqual(a, b []CIDRMember) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// CollapseCIDRMembers combines overlapping and adjacent CIDRs into the
+// smallest list of CIDRs covering the same addresses. Each resulting member
+// keeps the name of the first input member it covers. Members must hold valid
+// IPv4 CIDRs; use normalizeCIDRMembers first for untrusted input.
+func CollapseCIDRMembers(members []CIDRMember) []CIDRMember {
+	ranges := make([]ipRange, 0, len(members))
+	parsed := make([]ipRange, len(members))
+	for i, member := range members {
+		r, _, _ := parseIPv4Range(member.CIDR)
+		parsed[i] = r
+		ranges = append(ranges, r)
+	}
+
+	collapsed := make([]CIDRMember, 0)
+	for _, r := range mergeIPRanges(ranges) {
+		for _, block := range rangeToCIDRs(r) {
+			name := ""
+			for i, p := range parsed {
+				if p.lo <= block.hi && block.lo <= p.hi {
+					name = members[i].CIDRTag
+					break
+				}
+			}
+			collapsed = append(collapsed, CIDRMember{CIDRTag: name, CIDR: formatIPRangeCIDR(block)})
+		}
+	}
+	return collapsed
+}
+
+// normalizeCIDRMembers checks that every member has a name and a valid IPv4
+// CIDR, and rewrites the CIDRs in canonical network/prefix form.
+func normalizeCIDRMembers(members []CIDRMember) ([]CIDRMember, error) {
+	normalized := make([]CIDRMember, 0, len(members))
+	for _, member := range members {
+		name := strings.TrimSpace(member.CIDRTag)
+		if name == "" {
+			return nil, fmt.Errorf("firewall tag member %q has no name", member.CIDR)
+		}
+		cidr, err := canonicalCIDR(member.CIDR)
+		if err != nil {
+			return nil, fmt.Errorf("firewall tag member %s: %v", name, err)
+		}
+		normalized = append(normalized, CIDRMember{CIDRTag: name, CIDR: cidr})
+	}
+	return normalized, nil
+}
+
+// canonicalCIDR validates an IPv4 address or CIDR and returns it as
+// network/prefix, so "10.0.0.5/24" becomes "10.0.0.0/24" and "10.0.0.5"
+// becomes "10.0.0.5/32".
+func canonicalCIDR(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	r, ok, err := parseIPv4Range(value)
+	if err != nil {
+		return "", err
+	}
+	if !ok || value == "any" {
+		return "", fmt.Errorf("invalid CIDR %q", value)
+	}
+	return formatIPRangeCIDR(r), nil
+}
+
+// subtractIPRanges returns the parts of r not covered by any of cuts, in
+// ascending order.
+func subtractIPRanges(r ipRange, cuts []ipRange) []ipRange {
+	left := make([]ipRange, 0)
+	lo := uint64(r.lo)
+	for _, cut := range mergeIPRanges(cuts) {
+		if uint64(cut.hi) < lo || cut.lo > r.hi {
+			continue
+		}
+		if uint64(cut.lo) > lo {
+			left = append(left, ipRange{uint32(lo), cut.lo - 1})
+		}
+		lo = uint64(cut.hi) + 1
+	}
+	if lo <= uint64(r.hi) {
+		left = append(left, ipRange{uint32(lo), r.hi})
+	}
+	return left
+}
+
+// rangeToCIDRs splits an address range into the fewest aligned CIDR blocks.
+func rangeToCIDRs(r ipRange) []ipRange {
+	blocks := make([]ipRange, 0)
+	lo := uint64(r.lo)
+	hi := uint64(r.hi)
+	for lo <= hi {
+		// the largest block aligned at lo that does not pass hi
+		size := uint64(1) << 32
+		if lo != 0 {
+			size = uint64(1) << uint(bits.TrailingZeros64(lo))
+		}
+		for lo+size-1 > hi {
+			size >>= 1
+		}
+		blocks = append(blocks, ipRange{uint32(lo), uint32(lo + size - 1)})
+		lo += size
+	}
+	return blocks
+}
+
+// formatIPRangeCIDR renders an aligned CIDR block as network/prefix.
+func formatIPRangeCIDR(r ipRange) string {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, r.lo)
+	prefix := 32 - bits.Len32(r.hi-r.lo)
+	return fmt.Sprintf("%s/%d", ip.String(), prefix)
+}
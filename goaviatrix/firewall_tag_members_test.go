@@ -0,0 +1,172 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollapseCIDRMembers(t *testing.T) {
+	members := []CIDRMember{
+		{CIDRTag: "a", CIDR: "10.0.0.0/25"},
+		{CIDRTag: "b", CIDR: "10.0.0.128/25"},
+		{CIDRTag: "c", CIDR: "10.0.1.0/24"},
+		{CIDRTag: "d", CIDR: "10.0.1.7/32"},
+		{CIDRTag: "e", CIDR: "192.168.1.0/24"},
+		{CIDRTag: "f", CIDR: "10.0.2.0/24"},
+	}
+	assert.Equal(t, []CIDRMember{
+		{CIDRTag: "a", CIDR: "10.0.0.0/23"},
+		{CIDRTag: "f", CIDR: "10.0.2.0/24"},
+		{CIDRTag: "e", CIDR: "192.168.1.0/24"},
+	}, CollapseCIDRMembers(members))
+}
+
+func TestCanonicalCIDR(t *testing.T) {
+	for in, want := range map[string]string{
+		"10.0.0.5/24": "10.0.0.0/24",
+		"10.0.0.5":    "10.0.0.5/32",
+		"0.0.0.0/0":   "0.0.0.0/0",
+	} {
+		got, err := canonicalCIDR(in)
+		assert.Nil(t, err)
+		assert.Equal(t, want, got)
+	}
+	for _, in := range []string{"", "web", "10.0.0.0/33", "::1/128", "any"} {
+		_, err := canonicalCIDR(in)
+		assert.NotNil(t, err, in)
+	}
+}
+
+func TestAddRemoveFirewallTagMembers(t *testing.T) {
+	var updates []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_policy_members":
+			w.Write([]byte(listPolicyMembersDB))
+		case "update_policy_members":
+			update := ""
+			for i := 0; r.Form.Get(fmt.Sprintf("new_policies[%d][cidr]", i)) != ""; i++ {
+				update += fmt.Sprintf("%s=%s;", r.Form.Get(fmt.Sprintf("new_policies[%d][name]", i)),
+					r.Form.Get(fmt.Sprintf("new_policies[%d][cidr]", i)))
+			}
+			updates = append(updates, update)
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	if err != nil {
+		fmt.Println("unable to create client")
+	}
+	assert.Nil(t, err)
+
+	tag, err := client.AddFirewallTagMembers(&FirewallTag{
+		Name: "db",
+		CIDRList: []CIDRMember{
+			{CIDRTag: "db3", CIDR: "10.9.1.0/24"},
+			{CIDRTag: "dup", CIDR: "10.9.0.0/24"},
+		},
+	}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(tag.CIDRList))
+
+	tag, err = client.AddFirewallTagMembers(&FirewallTag{
+		Name:     "db",
+		CIDRList: []CIDRMember{{CIDRTag: "db3", CIDR: "10.9.1.0/24"}},
+	}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []CIDRMember{{CIDRTag: "db1", CIDR: "10.9.0.0/23"}}, tag.CIDRList)
+
+	_, err = client.AddFirewallTagMembers(&FirewallTag{
+		Name:     "db",
+		CIDRList: []CIDRMember{{CIDRTag: "bad", CIDR: "10.9.1.0/40"}},
+	}, false)
+	assert.NotNil(t, err)
+
+	tag, err = client.RemoveFirewallTagMembers(&FirewallTag{
+		Name:     "db",
+		CIDRList: []CIDRMember{{CIDR: "10.9.1.5"}, {CIDR: "172.16.0.0/12"}},
+	}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []CIDRMember{{CIDRTag: "db1", CIDR: "10.9.0.0/24"}}, tag.CIDRList)
+
+	assert.Equal(t, []string{
+		"db1=10.9.0.0/24;db2=10.9.1.5/32;db3=10.9.1.0/24;",
+		"db1=10.9.0.0/23;",
+		"db1=10.9.0.0/24;",
+	}, updates)
+}
+
+func TestRemoveFirewallTagMembersPartial(t *testing.T) {
+	var updates []string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_policy_members":
+			w.Write([]byte(listPolicyMembersDB))
+		case "update_policy_members":
+			update := ""
+			for i := 0; r.Form.Get(fmt.Sprintf("new_policies[%d][cidr]", i)) != ""; i++ {
+				update += fmt.Sprintf("%s=%s;", r.Form.Get(fmt.Sprintf("new_policies[%d][name]", i)),
+					r.Form.Get(fmt.Sprintf("new_policies[%d][cidr]", i)))
+			}
+			updates = append(updates, update)
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	tag, err := client.RemoveFirewallTagMembers(&FirewallTag{
+		Name:     "db",
+		CIDRList: []CIDRMember{{CIDR: "10.9.0.64/26"}, {CIDRTag: "db1", CIDR: "10.9.1.0/24"}},
+	}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []CIDRMember{
+		{CIDRTag: "db1", CIDR: "10.9.0.0/26"},
+		{CIDRTag: "db1", CIDR: "10.9.0.128/25"},
+		{CIDRTag: "db2", CIDR: "10.9.1.5/32"},
+	}, tag.CIDRList)
+	assert.Equal(t, []string{"db1=10.9.0.0/26;db1=10.9.0.128/25;db2=10.9.1.5/32;"}, updates)
+
+	reads := 0
+	h2 := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_policy_members":
+			reads++
+			if reads > 1 {
+				w.Write([]byte(`{"return": true, "results": {"members": [{"name": "db1", "cidr": "10.9.0.0/24"}]}}`))
+				return
+			}
+			w.Write([]byte(listPolicyMembersDB))
+		case "update_policy_members":
+			t.Error("tag updated after a concurrent change")
+		}
+	})
+	httpClient2, teardown2 := testingHTTPClient(h2)
+	defer teardown2()
+	client, err = NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient2), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	_, err = client.RemoveFirewallTagMembers(&FirewallTag{
+		Name:     "db",
+		CIDRList: []CIDRMember{{CIDR: "10.9.0.0/24"}},
+	}, false)
+	assert.Equal(t, ErrFirewallTagChanged, err)
+}
@@ -0,0 +1,112 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listPolicyTags = `{
+		"return": true,
+		"results": ["db", "web"]
+	  }`
+	listVpcsSummary = `{
+		"return": true,
+		"results": [
+			{"vpc_name": "gw1", "vpc_id": "vpc-1"},
+			{"vpc_name": "gw2", "vpc_id": "vpc-2"}
+		]
+	  }`
+)
+
+// firewallTagTestClient serves a policy for gw1, none for gw2 and a failure
+// for the gateway named failing.
+func firewallTagTestClient(t *testing.T, deleted *[]string, failing string) (*Client, func()) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_policy_tags":
+			w.Write([]byte(listPolicyTags))
+		case "list_vpcs_summary":
+			w.Write([]byte(listVpcsSummary))
+		case "vpc_access_policy":
+			switch r.Form.Get("vpc_name") {
+			case failing:
+				w.Write([]byte(fixture("failResponse.json")))
+			case "gw1":
+				w.Write([]byte(vpcAccessPolicy))
+			default:
+				w.Write([]byte(`{"return": false, "reason": "Firewall policy for gw2 does not exist."}`))
+			}
+		case "del_policy_tag":
+			*deleted = append(*deleted, r.Form.Get("tag_name"))
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	if err != nil {
+		fmt.Println("unable to create client")
+	}
+	assert.Nil(t, err)
+	return client, teardown
+}
+
+func TestListFirewallTags(t *testing.T) {
+	client, teardown := firewallTagTestClient(t, nil, "")
+	defer teardown()
+
+	tags, err := client.ListFirewallTags()
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(tags))
+	assert.Equal(t, "db", tags[0].Name)
+	assert.Equal(t, "web", tags[1].Name)
+}
+
+func TestDeleteFirewallTagRefuseIfInUse(t *testing.T) {
+	var deleted []string
+	client, teardown := firewallTagTestClient(t, &deleted, "")
+	defer teardown()
+
+	refs, err := client.FindFirewallTagReferences("db")
+	assert.Nil(t, err)
+	assert.Equal(t, []FirewallTagReference{
+		{GwName: "gw1", Index: 0, Field: "d_ip"},
+		{GwName: "gw1", Index: 1, Field: "d_ip"},
+	}, refs)
+
+	err = client.DeleteFirewallTag(&FirewallTag{Name: "db"}, RefuseIfInUse())
+	inUse, ok := err.(*FirewallTagInUseError)
+	assert.Equal(t, true, ok)
+	assert.Equal(t, 2, len(inUse.References))
+	assert.Equal(t, 0, len(deleted))
+
+	err = client.DeleteFirewallTag(&FirewallTag{Name: "web"}, RefuseIfInUse())
+	assert.Nil(t, err)
+	err = client.DeleteFirewallTag(&FirewallTag{Name: "db"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"web", "db"}, deleted)
+}
+
+func TestDeleteFirewallTagPolicyError(t *testing.T) {
+	var deleted []string
+	client, teardown := firewallTagTestClient(t, &deleted, "gw2")
+	defer teardown()
+
+	_, err := client.FindFirewallTagReferences("web")
+	if assert.Error(t, err) {
+		assert.Equal(t, "reading firewall policy of gw2: Invalid Request", err.Error())
+	}
+	err = client.DeleteFirewallTag(&FirewallTag{Name: "web"}, RefuseIfInUse())
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(deleted))
+
+	_, err = client.GetPolicy(&Firewall{GwName: "gw2"})
+	assert.Equal(t, ErrNotFound, err)
+}
@@ -9,21 +9,48 @@ import (
 	"strings"
 )
 
+// Per-domain rule actions. An empty Verdict leaves the decision to the tag's
+// mode (FQDNMode).
+const (
+	FQDNVerdictAllow = "Allow"
+	FQDNVerdictDeny  = "Deny"
+)
+
 type Filters struct {
 	FQDN     string `form:"fqdn,omitempty" json:"fqdn,omitempty"`
 	Protocol string `form:"proto,omitempty" json:"proto,omitempty"`
 	Port     string `form:"port,omitempty" json:"port,omitempty"`
+	Verdict  string `form:"verdict,omitempty" json:"verdict,omitempty"`
+}
+
+// GwFilter holds the source IP/CIDR list that restricts FQDN filtering on one
+// attached gateway. An empty SourceIPList applies the tag to all sources.
+type GwFilter struct {
+	GwName       string   `form:"gw_name,omitempty" json:"gw_name,omitempty"`
+	SourceIPList []string `form:"source_ips,omitempty" json:"source_ip_list,omitempty"`
 }
 
 // Gateway simple struct to hold fqdn details
 type FQDN struct {
-	FQDNTag    string     `form:"tag_name,omitempty" json:"tag_name,omitempty"`
-	Action     string     `form:"action,omitempty"`
-	CID        string     `form:"CID,omitempty"`
-	FQDNStatus string     `form:"status,omitempty" json:"status,omitempty"`
-	FQDNMode   string     `form:"color,omitempty" json:"color,omitempty"`
-	GwList     []string   `form:"gw_name,omitempty" json:"gw_name,omitempty"`
-	DomainList []*Filters `form:"domain_names[],omitempty" json:"domain_names,omitempty"`
+	FQDNTag      string     `form:"tag_name,omitempty" json:"tag_name,omitempty"`
+	Action       string     `form:"action,omitempty"`
+	CID          string     `form:"CID,omitempty"`
+	FQDNStatus   string     `form:"status,omitempty" json:"status,omitempty"`
+	FQDNMode     string     `form:"color,omitempty" json:"color,omitempty"`
+	GwList       []string   `form:"gw_name,omitempty" json:"gw_name,omitempty"`
+	GwFilterList []GwFilter `form:"-" json:"gw_filter_list,omitempty"`
+	DomainList   []*Filters `form:"domain_names[],omitempty" json:"domain_names,omitempty"`
+}
+
+// FindGwFilter returns the source IP filter configured for gwName, or nil if the
+// tag has none for that gateway.
+func (fqdn *FQDN) FindGwFilter(gwName string) *GwFilter {
+	for i := range fqdn.GwFilterList {
+		if fqdn.GwFilterList[i].GwName == gwName {
+			return &fqdn.GwFilterList[i]
+		}
+	}
+	return nil
 }
 
 type ResultListResp struct {
@@ -100,16 +127,29 @@ func (c *Client) UpdateFQDNMode(fqdn *FQDN) error {
 	return nil
 }
 
+// UpdateDomains replaces the tag's domain rules. The rules are checked with
+// ValidateFQDNFilters and their normalized form is sent to the controller;
+// fqdn.DomainList itself is left as given. Rules used to be sent unchecked,
+// so some that were passed on before, such as a tcp rule without a port or
+// with a signed port, are now rejected without any call being made. icmp and
+// all rules may still leave the port empty.
 func (c *Client) UpdateDomains(fqdn *FQDN) error {
+	domains, err := ValidateFQDNFilters(fqdn.DomainList)
+	if err != nil {
+		return err
+	}
 	fqdn.CID = c.CID
 	fqdn.Action = "set_fqdn_filter_tag_domain_names"
 	log.Printf("[INFO] Update domains: %#v", fqdn)
 
 	verb := "POST"
 	body := fmt.Sprintf("CID=%s&action=%s&tag_name=%s", c.CID, fqdn.Action, fqdn.FQDNTag)
-	for i, dn := range fqdn.DomainList {
+	for i, dn := range domains {
 		body = body + fmt.Sprintf("&domain_names[%d][fqdn]=%s&domain_names[%d]"+
 			"[proto]=%s&domain_names[%d][port]=%s", i, dn.FQDN, i, dn.Protocol, i, dn.Port)
+		if dn.Verdict != "" {
+			body = body + fmt.Sprintf("&domain_names[%d][verdict]=%s", i, dn.Verdict)
+		}
 	}
 	log.Printf("[TRACE] %s %s Body: %s", verb, c.baseURL, body)
 	req, err := http.NewRequest(verb, c.baseURL, strings.NewReader(body))
@@ -149,10 +189,56 @@ func (c *Client) AttachGws(fqdn *FQDN) error {
 		if !data.Return {
 			return errors.New(data.Reason)
 		}
+		if gwFilter := fqdn.FindGwFilter(fqdn.GwList[i]); gwFilter != nil && len(gwFilter.SourceIPList) != 0 {
+			if err := c.UpdateSourceIPFilters(fqdn, gwFilter); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// UpdateSourceIPFilters replaces the source IP list that limits the FQDN tag
+// on an attached gateway. An empty list removes the restriction.
+func (c *Client) UpdateSourceIPFilters(fqdn *FQDN, gwFilter *GwFilter) error {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=update_fqdn_filter_tag_source_ip_filters&tag_name=%s"+
+		"&gw_name=%s&source_ips=%s", c.CID, fqdn.FQDNTag, gwFilter.GwName, strings.Join(gwFilter.SourceIPList, ","))
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return err
+	}
+	var data APIResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+	if !data.Return {
+		return errors.New(data.Reason)
 	}
 	return nil
 }
 
+// ListSourceIPFilters returns the source IP list configured for the FQDN tag
+// on the given attached gateway.
+func (c *Client) ListSourceIPFilters(fqdn *FQDN, gwName string) (*GwFilter, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_fqdn_filter_tag_source_ip_filters&tag_name=%s"+
+		"&gw_name=%s", c.CID, fqdn.FQDNTag, gwName)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data ResultListResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		return nil, errors.New(data.Reason)
+	}
+	return &GwFilter{
+		GwName:       gwName,
+		SourceIPList: data.Results,
+	}, nil
+}
+
 func (c *Client) DetachGws(fqdn *FQDN) error {
 	path := c.baseURL + fmt.Sprintf("?CID=%s&action=detach_fqdn_filter_tag_from_gw&tag_name=%s", c.CID,
 		fqdn.FQDNTag)
@@ -204,21 +290,44 @@ func (c *Client) ListFQDNTags() ([]*FQDN, error) {
 	return tags, nil
 }
 
+// GetFQDNTag fills in the mode and status of the tag fqdn.FQDNTag together
+// with its domain rules, attached gateways and their source IP filters. This
+// takes one call per attached gateway on top of the tag, domain and gateway
+// lists.
 func (c *Client) GetFQDNTag(fqdn *FQDN) (*FQDN, error) {
 	tags, err := c.ListFQDNTags()
 	if err != nil {
 		return nil, err
 	}
 
+	found := false
 	for _, tag := range tags {
 		if tag.FQDNTag == fqdn.FQDNTag {
 			fqdn.FQDNMode = tag.FQDNMode
 			fqdn.FQDNStatus = tag.FQDNStatus
-			return fqdn, nil
+			found = true
+			break
 		}
 	}
-	log.Printf("[INFO] Couldn't find Aviatrix FQDN tag %s", fqdn.FQDNTag)
-	return nil, ErrNotFound
+	if !found {
+		log.Printf("[INFO] Couldn't find Aviatrix FQDN tag %s", fqdn.FQDNTag)
+		return nil, ErrNotFound
+	}
+	if _, err := c.ListDomains(fqdn); err != nil {
+		return nil, err
+	}
+	if _, err := c.ListGws(fqdn); err != nil {
+		return nil, err
+	}
+	fqdn.GwFilterList = nil
+	for _, gwName := range fqdn.GwList {
+		gwFilter, err := c.ListSourceIPFilters(fqdn, gwName)
+		if err != nil {
+			return nil, err
+		}
+		fqdn.GwFilterList = append(fqdn.GwFilterList, *gwFilter)
+	}
+	return fqdn, nil
 }
 
 func (c *Client) ListDomains(fqdn *FQDN) (*FQDN, error) {
@@ -235,17 +344,25 @@ func (c *Client) ListDomains(fqdn *FQDN) (*FQDN, error) {
 		return nil, err
 	}
 	dn := data
-	names := dn["results"].([]interface{})
-	for _, domain := range names {
-		dn := domain.(map[string]interface{})
-		//log.Printf("[TRACE] domain ------------------------->>>>>>>>>>>>: %#v", dn["fqdn"])
-		//log.Printf("[TRACE] domain ------------------------->>>>>>>>>>>>: %#v", dn["protocol"])
-		//log.Printf("[TRACE] domain ------------------------->>>>>>>>>>>>: %#v", dn["port"])
-		fqdnFilter := Filters{
-			FQDN:     dn["fqdn"].(string),
-			Protocol: dn["proto"].(string),
-			Port:     dn["port"].(string),
+	if ok, isBool := dn["return"].(bool); isBool && !ok {
+		reason, _ := dn["reason"].(string)
+		return nil, errors.New(reason)
+	}
+	names, ok := dn["results"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected domain list for FQDN tag %s", fqdn.FQDNTag)
+	}
+	fqdn.DomainList = nil
+	for i, domain := range names {
+		dn, ok := domain.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unexpected domain rule %d for FQDN tag %s", i, fqdn.FQDNTag)
 		}
+		fqdnFilter := Filters{}
+		fqdnFilter.FQDN, _ = dn["fqdn"].(string)
+		fqdnFilter.Protocol, _ = dn["proto"].(string)
+		fqdnFilter.Port, _ = dn["port"].(string)
+		fqdnFilter.Verdict, _ = dn["verdict"].(string)
 		//log.Printf("[TRACE] DOMAIN key FOUND ------------------------>>>>>>>>>>>>: %#v",fqdnFilter)
 		fqdn.DomainList = append(fqdn.DomainList, &fqdnFilter)
 	}
@@ -0,0 +1,231 @@
+package goaviatrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FQDNChangeReport describes the operations needed to bring an FQDN tag from
+// its current state to the desired one, as returned by PlanFQDNTag and
+// ApplyFQDNTag.
+type FQDNChangeReport struct {
+	TagName         string
+	Create          bool
+	StatusChange    string
+	ModeChange      string
+	DomainsAdded    []*Filters
+	DomainsRemoved  []*Filters
+	GwsAttached     []string
+	GwsDetached     []string
+	SourceIPUpdates []GwFilter
+	Applied         bool
+}
+
+// HasChanges reports whether the plan contains any operation.
+func (r *FQDNChangeReport) HasChanges() bool {
+	return r.Create || r.StatusChange != "" || r.ModeChange != "" ||
+		len(r.DomainsAdded) != 0 || len(r.DomainsRemoved) != 0 ||
+		len(r.GwsAttached) != 0 || len(r.GwsDetached) != 0 ||
+		len(r.SourceIPUpdates) != 0
+}
+
+// String renders the plan one operation per line, for use in change reviews.
+func (r *FQDNChangeReport) String() string {
+	var b strings.Builder
+	if r.Create {
+		fmt.Fprintf(&b, "+ tag %s\n", r.TagName)
+	}
+	if r.ModeChange != "" {
+		fmt.Fprintf(&b, "~ mode %s\n", r.ModeChange)
+	}
+	for _, dn := range r.DomainsRemoved {
+		fmt.Fprintf(&b, "- domain %s\n", filterKey(dn))
+	}
+	for _, dn := range r.DomainsAdded {
+		fmt.Fprintf(&b, "+ domain %s\n", filterKey(dn))
+	}
+	for _, gw := range r.GwsDetached {
+		fmt.Fprintf(&b, "- gateway %s\n", gw)
+	}
+	for _, gw := range r.GwsAttached {
+		fmt.Fprintf(&b, "+ gateway %s\n", gw)
+	}
+	for _, gwFilter := range r.SourceIPUpdates {
+		fmt.Fprintf(&b, "~ source ips %s: [%s]\n", gwFilter.GwName, strings.Join(gwFilter.SourceIPList, ","))
+	}
+	if r.StatusChange != "" {
+		fmt.Fprintf(&b, "~ status %s\n", r.StatusChange)
+	}
+	return b.String()
+}
+
+// PlanFQDNTag reads the current state of desired.FQDNTag and returns the
+// changes ApplyFQDNTag would make, without making them. Desired domain rules
+// are validated and normalized first, so a bad rule is reported before
+// anything is changed. Domain rules, attached gateways and source IP filters
+// are treated as complete sets; an empty FQDNStatus or FQDNMode leaves that
+// setting untouched.
+func (c *Client) PlanFQDNTag(ctx context.Context, desired *FQDN) (*FQDNChangeReport, error) {
+	if desired.FQDNTag == "" {
+		return nil, errors.New("FQDN tag name is required")
+	}
+	domains, err := ValidateFQDNFilters(desired.DomainList)
+	if err != nil {
+		return nil, err
+	}
+	normalized := *desired
+	normalized.DomainList = domains
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	current, err := c.GetFQDNTag(&FQDN{FQDNTag: desired.FQDNTag})
+	if err == ErrNotFound {
+		current = nil
+	} else if err != nil {
+		return nil, err
+	}
+	return diffFQDN(current, &normalized), nil
+}
+
+// ApplyFQDNTag reconciles an FQDN tag with desired, creating it if needed,
+// and returns the changes made. A tag being disabled is disabled before any
+// other change and a tag being enabled is enabled last, so traffic is never
+// filtered by a half-applied rule set. On error the report lists the full plan
+// with Applied left false.
+func (c *Client) ApplyFQDNTag(ctx context.Context, desired *FQDN) (*FQDNChangeReport, error) {
+	report, err := c.PlanFQDNTag(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if !report.HasChanges() {
+		return report, nil
+	}
+
+	tag := desired.FQDNTag
+	steps := make([]func() error, 0)
+	if report.Create {
+		steps = append(steps, func() error { return c.CreateFQDN(&FQDN{FQDNTag: tag}) })
+	}
+	if report.StatusChange == "disabled" {
+		steps = append(steps, func() error {
+			return c.UpdateFQDNStatus(&FQDN{FQDNTag: tag, FQDNStatus: report.StatusChange})
+		})
+	}
+	if report.ModeChange != "" {
+		steps = append(steps, func() error {
+			return c.UpdateFQDNMode(&FQDN{FQDNTag: tag, FQDNMode: report.ModeChange})
+		})
+	}
+	if len(report.DomainsAdded) != 0 || len(report.DomainsRemoved) != 0 {
+		steps = append(steps, func() error {
+			return c.UpdateDomains(&FQDN{FQDNTag: tag, DomainList: desired.DomainList})
+		})
+	}
+	if len(report.GwsDetached) != 0 {
+		steps = append(steps, func() error {
+			return c.DetachGws(&FQDN{FQDNTag: tag, GwList: report.GwsDetached})
+		})
+	}
+	if len(report.GwsAttached) != 0 {
+		steps = append(steps, func() error {
+			return c.AttachGws(&FQDN{FQDNTag: tag, GwList: report.GwsAttached})
+		})
+	}
+	for i := range report.SourceIPUpdates {
+		gwFilter := &report.SourceIPUpdates[i]
+		steps = append(steps, func() error { return c.UpdateSourceIPFilters(&FQDN{FQDNTag: tag}, gwFilter) })
+	}
+	if report.StatusChange != "" && report.StatusChange != "disabled" {
+		steps = append(steps, func() error {
+			return c.UpdateFQDNStatus(&FQDN{FQDNTag: tag, FQDNStatus: report.StatusChange})
+		})
+	}
+
+	for _, step := range steps {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+		if err := step(); err != nil {
+			return report, err
+		}
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// diffFQDN computes the change report between current and desired. A nil
+// current means the tag does not exist yet.
+func diffFQDN(current, desired *FQDN) *FQDNChangeReport {
+	report := &FQDNChangeReport{TagName: desired.FQDNTag}
+	if current == nil {
+		report.Create = true
+		current = &FQDN{FQDNTag: desired.FQDNTag}
+	}
+	if desired.FQDNStatus != "" && desired.FQDNStatus != current.FQDNStatus {
+		report.StatusChange = desired.FQDNStatus
+	}
+	if desired.FQDNMode != "" && desired.FQDNMode != current.FQDNMode {
+		report.ModeChange = desired.FQDNMode
+	}
+
+	currentDomains := make(map[string]bool)
+	for _, dn := range current.DomainList {
+		currentDomains[filterKey(dn)] = true
+	}
+	desiredDomains := make(map[string]bool)
+	for _, dn := range desired.DomainList {
+		key := filterKey(dn)
+		if !desiredDomains[key] && !currentDomains[key] {
+			report.DomainsAdded = append(report.DomainsAdded, dn)
+		}
+		desiredDomains[key] = true
+	}
+	for _, dn := range current.DomainList {
+		if !desiredDomains[filterKey(dn)] {
+			report.DomainsRemoved = append(report.DomainsRemoved, dn)
+		}
+	}
+
+	report.GwsAttached = Difference(desired.GwList, current.GwList)
+	report.GwsDetached = Difference(current.GwList, desired.GwList)
+	sort.Strings(report.GwsAttached)
+	sort.Strings(report.GwsDetached)
+
+	gwNames := append([]string{}, desired.GwList...)
+	sort.Strings(gwNames)
+	for i, gwName := range gwNames {
+		if i > 0 && gwNames[i-1] == gwName {
+			continue
+		}
+		var want, have []string
+		if gwFilter := desired.FindGwFilter(gwName); gwFilter != nil {
+			want = gwFilter.SourceIPList
+		}
+		if gwFilter := current.FindGwFilter(gwName); gwFilter != nil {
+			have = gwFilter.SourceIPList
+		}
+		newlyAttached := len(Difference([]string{gwName}, current.GwList)) != 0
+		if newlyAttached && len(want) == 0 {
+			continue
+		}
+		if len(Difference(want, have)) != 0 || len(Difference(have, want)) != 0 {
+			report.SourceIPUpdates = append(report.SourceIPUpdates, GwFilter{
+				GwName:       gwName,
+				SourceIPList: want,
+			})
+		}
+	}
+
+	return report
+}
+
+func filterKey(dn *Filters) string {
+	key := strings.ToLower(dn.FQDN) + " " + strings.ToLower(dn.Protocol) + " " + dn.Port
+	if dn.Verdict != "" {
+		key += " " + dn.Verdict
+	}
+	return key
+}
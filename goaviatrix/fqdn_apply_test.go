@@ -0,0 +1,85 @@
+package goaviatrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffFQDNCreate(t *testing.T) {
+	desired := &FQDN{
+		FQDNTag:    "egress",
+		FQDNStatus: "enabled",
+		FQDNMode:   "white",
+		GwList:     []string{"gw1"},
+		DomainList: []*Filters{{FQDN: "*.example.com", Protocol: "tcp", Port: "443"}},
+	}
+	report := diffFQDN(nil, desired)
+	assert.Equal(t, true, report.Create)
+	assert.Equal(t, "enabled", report.StatusChange)
+	assert.Equal(t, "white", report.ModeChange)
+	assert.Equal(t, 1, len(report.DomainsAdded))
+	assert.Equal(t, []string{"gw1"}, report.GwsAttached)
+	assert.Equal(t, 0, len(report.SourceIPUpdates))
+	assert.Equal(t, "+ tag egress\n"+
+		"~ mode white\n"+
+		"+ domain *.example.com tcp 443\n"+
+		"+ gateway gw1\n"+
+		"~ status enabled\n", report.String())
+}
+
+func TestDiffFQDNUpdate(t *testing.T) {
+	current := &FQDN{
+		FQDNTag:    "egress",
+		FQDNStatus: "enabled",
+		FQDNMode:   "white",
+		GwList:     []string{"gw1", "gw2"},
+		GwFilterList: []GwFilter{
+			{GwName: "gw1", SourceIPList: []string{"10.0.0.0/24"}},
+		},
+		DomainList: []*Filters{
+			{FQDN: "a.example.com", Protocol: "tcp", Port: "443"},
+			{FQDN: "b.example.com", Protocol: "tcp", Port: "443"},
+		},
+	}
+	desired := &FQDN{
+		FQDNTag: "egress",
+		GwList:  []string{"gw1", "gw3"},
+		GwFilterList: []GwFilter{
+			{GwName: "gw1", SourceIPList: []string{"10.0.0.0/24", "10.0.1.0/24"}},
+			{GwName: "gw3", SourceIPList: []string{"10.2.0.0/16"}},
+		},
+		DomainList: []*Filters{
+			{FQDN: "a.example.com", Protocol: "tcp", Port: "443"},
+			{FQDN: "c.example.com", Protocol: "tcp", Port: "80", Verdict: FQDNVerdictDeny},
+		},
+	}
+	report := diffFQDN(current, desired)
+	assert.Equal(t, false, report.Create)
+	assert.Equal(t, "", report.StatusChange)
+	assert.Equal(t, "", report.ModeChange)
+	assert.Equal(t, "c.example.com", report.DomainsAdded[0].FQDN)
+	assert.Equal(t, "b.example.com", report.DomainsRemoved[0].FQDN)
+	assert.Equal(t, []string{"gw3"}, report.GwsAttached)
+	assert.Equal(t, []string{"gw2"}, report.GwsDetached)
+	assert.Equal(t, []GwFilter{
+		{GwName: "gw1", SourceIPList: []string{"10.0.0.0/24", "10.0.1.0/24"}},
+		{GwName: "gw3", SourceIPList: []string{"10.2.0.0/16"}},
+	}, report.SourceIPUpdates)
+}
+
+func TestDiffFQDNNoChanges(t *testing.T) {
+	current := &FQDN{
+		FQDNTag:    "egress",
+		FQDNStatus: "enabled",
+		GwList:     []string{"gw1"},
+		DomainList: []*Filters{{FQDN: "a.example.com", Protocol: "tcp", Port: "443"}},
+	}
+	desired := &FQDN{
+		FQDNTag:    "egress",
+		FQDNStatus: "enabled",
+		GwList:     []string{"gw1"},
+		DomainList: []*Filters{{FQDN: "a.example.com", Protocol: "tcp", Port: "443"}},
+	}
+	assert.Equal(t, false, diffFQDN(current, desired).HasChanges())
+}
@@ -0,0 +1,247 @@
+package goaviatrix
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Normalized FQDNLogRecord actions.
+const (
+	FQDNActionAllowed = "allowed"
+	FQDNActionBlocked = "blocked"
+)
+
+// FQDNLogRecord is one domain/source pair seen by FQDN filtering on a gateway
+// with the tag Tag attached.
+type FQDNLogRecord struct {
+	Tag      string
+	GwName   string
+	Domain   string
+	SourceIP string
+	Action   string
+	HitCount int
+	LastSeen time.Time
+}
+
+type fqdnStatsResp struct {
+	Return  bool            `json:"return"`
+	Results json.RawMessage `json:"results"`
+	Reason  string          `json:"reason"`
+}
+
+type fqdnStatsEntry struct {
+	GwName   string      `json:"gw_name"`
+	Domain   string      `json:"fqdn"`
+	SourceIP string      `json:"source_ip"`
+	Action   string      `json:"action"`
+	HitCount json.Number `json:"hit_count"`
+	LastSeen interface{} `json:"last_seen"`
+}
+
+// GetFQDNStats fetches the FQDN egress statistics kept by the controller and
+// returns them as typed records. With tagName set the statistics of that tag
+// are returned, and gwName may be left empty to include every gateway of the
+// tag. With only gwName set the statistics of every tag attached to that
+// gateway are returned. The mode and domain rules of each tag are read first,
+// as they decide whether a matched domain was allowed or blocked.
+func (c *Client) GetFQDNStats(tagName string, gwName string) ([]FQDNLogRecord, error) {
+	if tagName == "" && gwName == "" {
+		return nil, errors.New("FQDN tag or gateway name is required")
+	}
+	tags, err := c.ListFQDNTags()
+	if err != nil {
+		return nil, err
+	}
+	records := make([]FQDNLogRecord, 0)
+	found := false
+	for _, tag := range tags {
+		if tagName != "" && tag.FQDNTag != tagName {
+			continue
+		}
+		if tagName == "" {
+			if _, err := c.ListGws(tag); err != nil {
+				return nil, err
+			}
+			if !containsString(tag.GwList, gwName) {
+				continue
+			}
+		}
+		found = true
+		if _, err := c.ListDomains(tag); err != nil {
+			return nil, err
+		}
+		tagRecords, err := c.getFQDNTagStats(tag, gwName)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, tagRecords...)
+	}
+	if !found {
+		return nil, ErrNotFound
+	}
+	sortFQDNLogRecords(records)
+	return records, nil
+}
+
+func (c *Client) getFQDNTagStats(tag *FQDN, gwName string) ([]FQDNLogRecord, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=get_fqdn_stats&tag_name=%s&gateway_name=%s", c.CID,
+		tag.FQDNTag, gwName)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFQDNStats(body, tag)
+}
+
+// ParseFQDNStats decodes a get_fqdn_stats response for tag. The controller
+// returns results either as a flat list of entries carrying gw_name or as an
+// object keyed by gateway name; both are accepted. A MATCHED entry takes the
+// verdict of the tag's domain rule for it, and when that rule has no verdict
+// it is allowed in white mode and blocked in black mode. A NOT MATCHED entry
+// is blocked in white mode and allowed in black mode. Records are sorted by
+// gateway, domain and source IP.
+func ParseFQDNStats(body []byte, tag *FQDN) ([]FQDNLogRecord, error) {
+	if tag.FQDNMode != "white" && tag.FQDNMode != "black" {
+		return nil, fmt.Errorf("unknown FQDN tag mode %q", tag.FQDNMode)
+	}
+	var data fqdnStatsResp
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		return nil, errors.New(data.Reason)
+	}
+
+	byGw := make(map[string][]fqdnStatsEntry)
+	var list []fqdnStatsEntry
+	if len(data.Results) == 0 || string(data.Results) == "null" {
+		return []FQDNLogRecord{}, nil
+	}
+	if err := json.Unmarshal(data.Results, &list); err != nil {
+		if err := json.Unmarshal(data.Results, &byGw); err != nil {
+			return nil, fmt.Errorf("unrecognized FQDN stats results: %v", err)
+		}
+	}
+	for gwName, entries := range byGw {
+		for _, entry := range entries {
+			if entry.GwName == "" {
+				entry.GwName = gwName
+			}
+			list = append(list, entry)
+		}
+	}
+
+	records := make([]FQDNLogRecord, 0, len(list))
+	for i, entry := range list {
+		record, err := entry.record(tag)
+		if err != nil {
+			return nil, fmt.Errorf("FQDN stats entry %d: %v", i, err)
+		}
+		records = append(records, record)
+	}
+	sortFQDNLogRecords(records)
+	return records, nil
+}
+
+func sortFQDNLogRecords(records []FQDNLogRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].GwName != records[j].GwName {
+			return records[i].GwName < records[j].GwName
+		}
+		if records[i].Domain != records[j].Domain {
+			return records[i].Domain < records[j].Domain
+		}
+		if records[i].SourceIP != records[j].SourceIP {
+			return records[i].SourceIP < records[j].SourceIP
+		}
+		return records[i].Tag < records[j].Tag
+	})
+}
+
+func (e *fqdnStatsEntry) record(tag *FQDN) (FQDNLogRecord, error) {
+	record := FQDNLogRecord{
+		Tag:      tag.FQDNTag,
+		GwName:   e.GwName,
+		Domain:   e.Domain,
+		SourceIP: e.SourceIP,
+	}
+	if record.Domain == "" {
+		return record, errors.New("missing domain")
+	}
+
+	action, err := parseFQDNAction(e.Action, tag.FQDNMode, fqdnRuleVerdict(tag.DomainList, e.Domain))
+	if err != nil {
+		return record, err
+	}
+	record.Action = action
+
+	if count := string(e.HitCount); count != "" {
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return record, fmt.Errorf("invalid hit count %q", count)
+		}
+		record.HitCount = n
+	}
+
+	record.LastSeen, err = parseTimestamp(e.LastSeen)
+	if err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// parseFQDNAction turns the match result of a stats entry into a verdict. A
+// match takes verdict, the verdict of the matching domain rule, and falls
+// back to the tag's mode when the rule has none.
+func parseFQDNAction(action string, mode string, verdict string) (string, error) {
+	var matched bool
+	switch strings.ToUpper(strings.TrimSpace(action)) {
+	case "MATCHED":
+		matched = true
+	case "NOT MATCHED":
+		matched = false
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+	if matched {
+		switch {
+		case strings.EqualFold(verdict, FQDNVerdictAllow):
+			return FQDNActionAllowed, nil
+		case strings.EqualFold(verdict, FQDNVerdictDeny):
+			return FQDNActionBlocked, nil
+		}
+	}
+	if matched == (mode == "white") {
+		return FQDNActionAllowed, nil
+	}
+	return FQDNActionBlocked, nil
+}
+
+// fqdnRuleVerdict returns the verdict of the rule in rules matching domain.
+// A rule naming the domain itself is preferred over a wildcard rule covering
+// it.
+func fqdnRuleVerdict(rules []*Filters, domain string) string {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	wildcard, wildcardFound := "", false
+	for _, rule := range rules {
+		name := strings.TrimSuffix(strings.ToLower(rule.FQDN), ".")
+		switch {
+		case name == domain:
+			return rule.Verdict
+		case !wildcardFound && (name == "*" || strings.HasPrefix(name, "*.") && strings.HasSuffix(domain, name[1:])):
+			wildcard, wildcardFound = rule.Verdict, true
+		}
+	}
+	return wildcard
+}
@@ -0,0 +1,198 @@
+package goaviatrix
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fqdnStatsTag is a tag in mode with a wildcard rule without a verdict and a
+// rule denying one domain inside the wildcard.
+func fqdnStatsTag(mode string) *FQDN {
+	return &FQDN{
+		FQDNTag:  "egress",
+		FQDNMode: mode,
+		DomainList: []*Filters{
+			{FQDN: "*.example.com", Protocol: "tcp", Port: "443"},
+			{FQDN: "bad.example.com", Protocol: "tcp", Port: "443", Verdict: FQDNVerdictDeny},
+		},
+	}
+}
+
+func TestParseFQDNStatsEntry(t *testing.T) {
+	lastSeen := time.Date(2019, 3, 1, 10, 15, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		mode   string
+		entry  string
+		action string
+		hits   int
+		seen   time.Time
+	}{
+		{
+			name:   "matched rule without verdict in white mode",
+			mode:   "white",
+			entry:  `{"fqdn": "www.example.com", "action": "MATCHED", "hit_count": 42, "last_seen": 1551435300}`,
+			action: FQDNActionAllowed, hits: 42, seen: lastSeen,
+		},
+		{
+			name:   "matched deny rule in white mode",
+			mode:   "white",
+			entry:  `{"fqdn": "bad.example.com", "action": "MATCHED"}`,
+			action: FQDNActionBlocked,
+		},
+		{
+			name:   "matched rule without verdict in black mode",
+			mode:   "black",
+			entry:  `{"fqdn": "www.example.com", "action": "MATCHED"}`,
+			action: FQDNActionBlocked,
+		},
+		{
+			name:   "not matched in white mode",
+			mode:   "white",
+			entry:  `{"fqdn": "malware.test", "action": "NOT MATCHED"}`,
+			action: FQDNActionBlocked,
+		},
+		{
+			name:   "not matched in black mode",
+			mode:   "black",
+			entry:  `{"fqdn": "malware.test", "action": "not matched"}`,
+			action: FQDNActionAllowed,
+		},
+		{
+			name:   "hit count as string",
+			mode:   "white",
+			entry:  `{"fqdn": "www.example.com", "action": "MATCHED", "hit_count": "3"}`,
+			action: FQDNActionAllowed, hits: 3,
+		},
+		{
+			name:   "last seen as string",
+			mode:   "white",
+			entry:  `{"fqdn": "www.example.com", "action": "MATCHED", "last_seen": "2019-03-01 10:15:00"}`,
+			action: FQDNActionAllowed, seen: lastSeen,
+		},
+		{
+			name:   "last seen as RFC 3339",
+			mode:   "white",
+			entry:  `{"fqdn": "www.example.com", "action": "MATCHED", "last_seen": "2019-03-01T10:15:00Z"}`,
+			action: FQDNActionAllowed, seen: lastSeen,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"return": true, "results": [` + tt.entry + `]}`
+			records, err := ParseFQDNStats([]byte(body), fqdnStatsTag(tt.mode))
+			assert.Nil(t, err)
+			if assert.Equal(t, 1, len(records)) {
+				assert.Equal(t, "egress", records[0].Tag)
+				assert.Equal(t, tt.action, records[0].Action)
+				assert.Equal(t, tt.hits, records[0].HitCount)
+				assert.Equal(t, tt.seen, records[0].LastSeen)
+			}
+		})
+	}
+}
+
+func TestParseFQDNStatsByGateway(t *testing.T) {
+	body := `{"return": true, "results": {
+		"gw2": [{"fqdn": "www.example.com", "source_ip": "10.0.0.2", "action": "MATCHED"}],
+		"gw1": [
+			{"fqdn": "www.example.com", "source_ip": "10.0.0.1", "action": "MATCHED"},
+			{"gw_name": "gw1", "fqdn": "a.example.com", "source_ip": "10.0.0.1", "action": "MATCHED"}
+		]
+	}}`
+	records, err := ParseFQDNStats([]byte(body), fqdnStatsTag("white"))
+	assert.Nil(t, err)
+	if assert.Equal(t, 3, len(records)) {
+		assert.Equal(t, FQDNLogRecord{Tag: "egress", GwName: "gw1", Domain: "a.example.com", SourceIP: "10.0.0.1",
+			Action: FQDNActionAllowed}, records[0])
+		assert.Equal(t, "www.example.com", records[1].Domain)
+		assert.Equal(t, "gw2", records[2].GwName)
+	}
+}
+
+func TestParseFQDNStatsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		mode string
+		body string
+	}{
+		{name: "failed", mode: "white", body: `{"return": false, "reason": "FQDN tag egress does not exist"}`},
+		{name: "invalid json", mode: "white", body: `{"return": true, "results": [`},
+		{name: "unknown action", mode: "white", body: `{"return": true, "results": [{"fqdn": "a.com", "action": "maybe"}]}`},
+		{name: "missing domain", mode: "white", body: `{"return": true, "results": [{"action": "MATCHED"}]}`},
+		{name: "bad hit count", mode: "white",
+			body: `{"return": true, "results": [{"fqdn": "a.com", "action": "MATCHED", "hit_count": "x"}]}`},
+		{name: "unknown mode", mode: "", body: `{"return": true, "results": []}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseFQDNStats([]byte(tt.body), fqdnStatsTag(tt.mode))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestGetFQDNStats(t *testing.T) {
+	calls := make([]string, 0)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		if action != "login" {
+			calls = append(calls, action+" "+r.Form.Get("tag_name")+" "+r.Form.Get("gateway_name"))
+		}
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_fqdn_filter_tags":
+			w.Write([]byte(`{"return": true, "results": {
+				"egress": {"wbmode": "white", "state": "enabled"},
+				"other": {"wbmode": "black", "state": "enabled"}
+			}}`))
+		case "list_fqdn_filter_tag_domain_names":
+			w.Write([]byte(listFQDNDomains))
+		case "list_fqdn_filter_tag_attached_gws":
+			if r.Form.Get("tag_name") == "egress" {
+				w.Write([]byte(listFQDNGws))
+			} else {
+				w.Write([]byte(`{"return": true, "results": ["gw3"]}`))
+			}
+		case "get_fqdn_stats":
+			w.Write([]byte(`{"return": true, "results": [
+				{"gw_name": "gw1", "fqdn": "bad.example.com", "source_ip": "10.0.0.1", "action": "MATCHED"}
+			]}`))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	records, err := client.GetFQDNStats("egress", "gw1")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(records)) {
+		assert.Equal(t, FQDNActionBlocked, records[0].Action)
+	}
+	assert.Equal(t, "list_fqdn_filter_tag_domain_names egress ,get_fqdn_stats egress gw1",
+		strings.Join(calls[1:], ","))
+
+	// the tags of a gateway are looked up when no tag is given
+	calls = calls[:0]
+	records, err = client.GetFQDNStats("", "gw1")
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(records)) {
+		assert.Equal(t, "egress", records[0].Tag)
+	}
+	assert.True(t, strings.Contains(strings.Join(calls, ","), "get_fqdn_stats egress gw1"))
+	assert.False(t, strings.Contains(strings.Join(calls, ","), "get_fqdn_stats other"))
+
+	_, err = client.GetFQDNStats("", "gw9")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = client.GetFQDNStats("missing", "")
+	assert.Equal(t, ErrNotFound, err)
+	_, err = client.GetFQDNStats("", "")
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,156 @@
+package goaviatrix
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listFQDNTags = `{
+		"return": true,
+		"results": {"egress": {"wbmode": "white", "state": "enabled"}}
+	  }`
+	listFQDNDomains = `{
+		"return": true,
+		"results": [
+			{"fqdn": "*.example.com", "proto": "tcp", "port": "443"},
+			{"fqdn": "bad.example.com", "proto": "tcp", "port": "443", "verdict": "Deny"}
+		]
+	  }`
+	listFQDNGws = `{
+		"return": true,
+		"results": ["gw1", "gw2"]
+	  }`
+)
+
+// fqdnTestClient serves the egress tag and records every action, with its
+// gateway and source IPs where given, in calls.
+func fqdnTestClient(t *testing.T, calls *[]string, sourceIPs map[string]string) (*Client, func()) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		if action != "login" {
+			*calls = append(*calls, action+" "+r.Form.Get("gw_name")+r.Form.Get("source_ips"))
+		}
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_fqdn_filter_tags":
+			w.Write([]byte(listFQDNTags))
+		case "list_fqdn_filter_tag_domain_names":
+			w.Write([]byte(listFQDNDomains))
+		case "list_fqdn_filter_tag_attached_gws":
+			w.Write([]byte(listFQDNGws))
+		case "list_fqdn_filter_tag_source_ip_filters":
+			ips, ok := sourceIPs[r.Form.Get("gw_name")]
+			if !ok {
+				w.Write([]byte(`{"return": false, "reason": "gateway is not attached"}`))
+				return
+			}
+			w.Write([]byte(`{"return": true, "results": [` + ips + `]}`))
+		default:
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+	return client, teardown
+}
+
+func TestGetFQDNTag(t *testing.T) {
+	calls := make([]string, 0)
+	client, teardown := fqdnTestClient(t, &calls, map[string]string{"gw1": `"10.0.0.0/24", "10.0.1.5"`, "gw2": ""})
+	defer teardown()
+
+	fqdn, err := client.GetFQDNTag(&FQDN{FQDNTag: "egress"})
+	assert.Nil(t, err)
+	assert.Equal(t, "white", fqdn.FQDNMode)
+	assert.Equal(t, "enabled", fqdn.FQDNStatus)
+	assert.Equal(t, []*Filters{
+		{FQDN: "*.example.com", Protocol: "tcp", Port: "443"},
+		{FQDN: "bad.example.com", Protocol: "tcp", Port: "443", Verdict: FQDNVerdictDeny},
+	}, fqdn.DomainList)
+	assert.Equal(t, []string{"gw1", "gw2"}, fqdn.GwList)
+	assert.Equal(t, []string{"10.0.0.0/24", "10.0.1.5"}, fqdn.FindGwFilter("gw1").SourceIPList)
+	assert.Equal(t, 0, len(fqdn.FindGwFilter("gw2").SourceIPList))
+	assert.Nil(t, fqdn.FindGwFilter("gw3"))
+
+	calls = calls[:0]
+	_, err = client.GetFQDNTag(&FQDN{FQDNTag: "other"})
+	assert.Equal(t, ErrNotFound, err)
+	assert.Equal(t, []string{"list_fqdn_filter_tags "}, calls)
+
+	client2, teardown2 := fqdnTestClient(t, &calls, map[string]string{"gw1": ""})
+	defer teardown2()
+	_, err = client2.GetFQDNTag(&FQDN{FQDNTag: "egress"})
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "gateway is not attached", err.Error())
+	}
+}
+
+func TestSourceIPFilters(t *testing.T) {
+	calls := make([]string, 0)
+	client, teardown := fqdnTestClient(t, &calls, map[string]string{"gw1": `"10.0.0.0/24"`})
+	defer teardown()
+
+	fqdn := &FQDN{FQDNTag: "egress"}
+	gwFilter, err := client.ListSourceIPFilters(fqdn, "gw1")
+	assert.Nil(t, err)
+	assert.Equal(t, &GwFilter{GwName: "gw1", SourceIPList: []string{"10.0.0.0/24"}}, gwFilter)
+	_, err = client.ListSourceIPFilters(fqdn, "gw9")
+	assert.NotNil(t, err)
+
+	calls = calls[:0]
+	err = client.UpdateSourceIPFilters(fqdn, &GwFilter{GwName: "gw1", SourceIPList: []string{"10.0.0.0/24", "10.0.2.0/24"}})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update_fqdn_filter_tag_source_ip_filters gw110.0.0.0/24,10.0.2.0/24"}, calls)
+
+	// only gateways with a source IP filter get one set when attached
+	calls = calls[:0]
+	fqdn.GwList = []string{"gw1", "gw2"}
+	fqdn.GwFilterList = []GwFilter{{GwName: "gw2", SourceIPList: []string{"10.0.3.0/24"}}}
+	assert.Nil(t, client.AttachGws(fqdn))
+	assert.Equal(t, []string{
+		"attach_fqdn_filter_tag_to_gw gw1",
+		"attach_fqdn_filter_tag_to_gw gw2",
+		"update_fqdn_filter_tag_source_ip_filters gw210.0.3.0/24",
+	}, calls)
+}
+
+func TestListDomainsMalformed(t *testing.T) {
+	for _, body := range []string{
+		`{"return": false, "reason": "tag does not exist"}`,
+		`{"return": true, "results": "none"}`,
+		`{"return": true, "results": ["example.com"]}`,
+	} {
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.ParseForm()
+			if r.Form.Get("action") == "login" {
+				w.Write([]byte(fixture("loginRespSuccess.json")))
+				return
+			}
+			w.Write([]byte(body))
+		})
+		httpClient, teardown := testingHTTPClient(h)
+		client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+		assert.Nil(t, err)
+		_, err = client.ListDomains(&FQDN{FQDNTag: "egress"})
+		assert.NotNil(t, err, body)
+		teardown()
+	}
+}
+
+func TestUpdateDomainsKeepsInput(t *testing.T) {
+	calls := make([]string, 0)
+	client, teardown := fqdnTestClient(t, &calls, nil)
+	defer teardown()
+
+	domains := []*Filters{{FQDN: "WWW.Example.COM.", Protocol: "TCP", Port: "443"}}
+	fqdn := &FQDN{FQDNTag: "egress", DomainList: domains}
+	assert.Nil(t, client.UpdateDomains(fqdn))
+	assert.Equal(t, []string{"set_fqdn_filter_tag_domain_names "}, calls)
+	assert.Equal(t, &Filters{FQDN: "WWW.Example.COM.", Protocol: "TCP", Port: "443"}, fqdn.DomainList[0])
+}
@@ -0,0 +1,201 @@
+package goaviatrix
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FQDNFilterError describes a domain rule rejected by ValidateFQDNFilters.
+type FQDNFilterError struct {
+	Index  int
+	Filter *Filters
+	Err    error
+}
+
+func (e *FQDNFilterError) Error() string {
+	return fmt.Sprintf("domain_names[%d] (%s %s %s): %v", e.Index, e.Filter.FQDN, e.Filter.Protocol,
+		e.Filter.Port, e.Err)
+}
+
+// FQDNFilterErrors collects every rule rejected by ValidateFQDNFilters.
+type FQDNFilterErrors []*FQDNFilterError
+
+func (e FQDNFilterErrors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// ValidateFQDNFilters checks a list of FQDN domain rules and returns
+// normalized copies in the same order. Domains and protocols are lowercased,
+// trailing dots are dropped and verdicts are capitalised. All invalid and
+// duplicate rules are reported together as FQDNFilterErrors.
+func ValidateFQDNFilters(filters []*Filters) ([]*Filters, error) {
+	var errs FQDNFilterErrors
+	normalized := make([]*Filters, 0, len(filters))
+	seen := make(map[string]int)
+	for i, filter := range filters {
+		if filter == nil {
+			errs = append(errs, &FQDNFilterError{Index: i, Filter: &Filters{}, Err: errors.New("empty rule")})
+			continue
+		}
+		nf, err := NormalizeFQDNFilter(filter)
+		if err != nil {
+			errs = append(errs, &FQDNFilterError{Index: i, Filter: filter, Err: err})
+			continue
+		}
+		port := nf.Port
+		if port == "" {
+			port = fqdnDefaultPorts[nf.Protocol]
+		}
+		key := nf.FQDN + " " + nf.Protocol + " " + port
+		if j, ok := seen[key]; ok {
+			errs = append(errs, &FQDNFilterError{Index: i, Filter: filter,
+				Err: fmt.Errorf("duplicate of domain_names[%d]", j)})
+			continue
+		}
+		seen[key] = i
+		normalized = append(normalized, nf)
+	}
+	if len(errs) != 0 {
+		return nil, errs
+	}
+	return normalized, nil
+}
+
+// fqdnDefaultPorts is the port of the protocols whose rules may leave the
+// port empty, as rules for them were accepted before they were validated.
+var fqdnDefaultPorts = map[string]string{
+	"icmp": "ping",
+	"all":  "all",
+}
+
+// NormalizeFQDNFilter validates a single domain rule and returns its
+// canonical form. Domains may be a fully qualified name, a wildcard such as
+// "*.example.com", or "*". Protocol must be tcp, udp, icmp or all; port is a
+// decimal number or range ("8000-8080") for tcp/udp, written without leading
+// zeros in the result, "ping" or empty for icmp and "all" or empty for all.
+func NormalizeFQDNFilter(filter *Filters) (*Filters, error) {
+	nf := &Filters{
+		FQDN:     strings.TrimSuffix(strings.ToLower(strings.TrimSpace(filter.FQDN)), "."),
+		Protocol: strings.ToLower(strings.TrimSpace(filter.Protocol)),
+		Port:     strings.ToLower(strings.TrimSpace(filter.Port)),
+		Verdict:  strings.TrimSpace(filter.Verdict),
+	}
+	if err := validateFQDNDomain(nf.FQDN); err != nil {
+		return nil, err
+	}
+
+	switch nf.Protocol {
+	case "tcp", "udp":
+		port, err := normalizeFQDNPort(nf.Port)
+		if err != nil {
+			return nil, err
+		}
+		nf.Port = port
+	case "icmp":
+		if nf.Port != "ping" && nf.Port != "" {
+			return nil, fmt.Errorf("port must be \"ping\" for icmp, got %q", filter.Port)
+		}
+	case "all":
+		if nf.Port != "all" && nf.Port != "" {
+			return nil, fmt.Errorf("port must be \"all\" for protocol all, got %q", filter.Port)
+		}
+	case "":
+		return nil, errors.New("protocol is required")
+	default:
+		return nil, fmt.Errorf("unsupported protocol %q", filter.Protocol)
+	}
+
+	switch strings.ToLower(nf.Verdict) {
+	case "":
+	case "allow":
+		nf.Verdict = FQDNVerdictAllow
+	case "deny":
+		nf.Verdict = FQDNVerdictDeny
+	default:
+		return nil, fmt.Errorf("unsupported verdict %q", filter.Verdict)
+	}
+	return nf, nil
+}
+
+func validateFQDNDomain(domain string) error {
+	if domain == "" {
+		return errors.New("domain is required")
+	}
+	if domain == "*" {
+		return nil
+	}
+	if len(domain) > 253 {
+		return fmt.Errorf("domain %q is longer than 253 characters", domain)
+	}
+	labels := strings.Split(domain, ".")
+	if labels[0] == "*" {
+		labels = labels[1:]
+		if len(labels) < 2 {
+			return fmt.Errorf("wildcard domain %q must cover at least two labels", domain)
+		}
+	}
+	for _, label := range labels {
+		if len(label) == 0 || len(label) > 63 {
+			return fmt.Errorf("domain %q has an empty or overlong label", domain)
+		}
+		if label[0] == '-' || label[len(label)-1] == '-' {
+			return fmt.Errorf("domain %q has a label starting or ending with '-'", domain)
+		}
+		for _, ch := range label {
+			if ch == '*' {
+				return fmt.Errorf("domain %q may only use '*' as its first label", domain)
+			}
+			if !(ch >= 'a' && ch <= 'z' || ch >= '0' && ch <= '9' || ch == '-' || ch == '_') {
+				return fmt.Errorf("domain %q contains invalid character %q", domain, ch)
+			}
+		}
+	}
+	return nil
+}
+
+// normalizeFQDNPort checks a tcp/udp port or port range and returns it with
+// its bounds in canonical decimal form, so that "080" and "80" compare equal.
+func normalizeFQDNPort(port string) (string, error) {
+	if port == "" {
+		return "", errors.New("port is required")
+	}
+	bounds := strings.SplitN(port, "-", 2)
+	low, err := parsePort(bounds[0])
+	if err != nil {
+		return "", err
+	}
+	// port 0 is not a real destination port
+	if low == 0 {
+		return "", fmt.Errorf("invalid port %q", port)
+	}
+	if len(bounds) == 1 {
+		return strconv.Itoa(low), nil
+	}
+	high, err := parsePort(bounds[1])
+	if err != nil {
+		return "", err
+	}
+	if low > high {
+		return "", fmt.Errorf("invalid port range %q", port)
+	}
+	return strconv.Itoa(low) + "-" + strconv.Itoa(high), nil
+}
+
+// parsePort parses a port number written in decimal digits only, so that
+// signs such as "+443" are rejected.
+func parsePort(s string) (int, error) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n > 65535 {
+		return 0, fmt.Errorf("invalid port %q", s)
+	}
+	return n, nil
+}
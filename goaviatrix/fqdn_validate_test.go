@@ -0,0 +1,97 @@
+package goaviatrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeFQDNFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  Filters
+		want    Filters
+		wantErr bool
+	}{
+		{
+			name:   "lowercase and trailing dot",
+			filter: Filters{FQDN: "WWW.Example.COM.", Protocol: "TCP", Port: "443", Verdict: "deny"},
+			want:   Filters{FQDN: "www.example.com", Protocol: "tcp", Port: "443", Verdict: FQDNVerdictDeny},
+		},
+		{
+			name:   "wildcard with port range",
+			filter: Filters{FQDN: "*.example.com", Protocol: "udp", Port: "8000-8080"},
+			want:   Filters{FQDN: "*.example.com", Protocol: "udp", Port: "8000-8080"},
+		},
+		{
+			name:   "icmp ping",
+			filter: Filters{FQDN: "example.com", Protocol: "icmp", Port: "ping"},
+			want:   Filters{FQDN: "example.com", Protocol: "icmp", Port: "ping"},
+		},
+		{
+			name:   "match all",
+			filter: Filters{FQDN: "*", Protocol: "all", Port: "all"},
+			want:   Filters{FQDN: "*", Protocol: "all", Port: "all"},
+		},
+		{
+			name:   "leading zeros",
+			filter: Filters{FQDN: "example.com", Protocol: "tcp", Port: "080-0443"},
+			want:   Filters{FQDN: "example.com", Protocol: "tcp", Port: "80-443"},
+		},
+		{
+			name:   "all without port",
+			filter: Filters{FQDN: "*", Protocol: "all"},
+			want:   Filters{FQDN: "*", Protocol: "all"},
+		},
+		{name: "signed port", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "+443"}, wantErr: true},
+		{name: "open range", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "80-"}, wantErr: true},
+		{name: "tcp without port", filter: Filters{FQDN: "a.com", Protocol: "tcp"}, wantErr: true},
+		{name: "wildcard in middle", filter: Filters{FQDN: "www.*.com", Protocol: "tcp", Port: "443"}, wantErr: true},
+		{name: "wildcard tld", filter: Filters{FQDN: "*.com", Protocol: "tcp", Port: "443"}, wantErr: true},
+		{name: "empty label", filter: Filters{FQDN: "a..com", Protocol: "tcp", Port: "443"}, wantErr: true},
+		{name: "bad character", filter: Filters{FQDN: "a b.com", Protocol: "tcp", Port: "443"}, wantErr: true},
+		{name: "bad protocol", filter: Filters{FQDN: "a.com", Protocol: "sctp", Port: "443"}, wantErr: true},
+		{name: "port out of range", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "70000"}, wantErr: true},
+		{name: "port zero", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "0"}, wantErr: true},
+		{name: "range from zero", filter: Filters{FQDN: "a.com", Protocol: "udp", Port: "0-80"}, wantErr: true},
+		{name: "reversed range", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "90-80"}, wantErr: true},
+		{name: "icmp with port", filter: Filters{FQDN: "a.com", Protocol: "icmp", Port: "443"}, wantErr: true},
+		{name: "bad verdict", filter: Filters{FQDN: "a.com", Protocol: "tcp", Port: "443", Verdict: "drop"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NormalizeFQDNFilter(&tt.filter)
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+}
+
+func TestValidateFQDNFiltersReportsAll(t *testing.T) {
+	filters := []*Filters{
+		{FQDN: "a.example.com", Protocol: "tcp", Port: "443"},
+		{FQDN: "A.example.com", Protocol: "TCP", Port: "443"},
+		{FQDN: "b.example.com", Protocol: "bogus", Port: "443"},
+		{FQDN: "c.example.com", Protocol: "tcp", Port: "80"},
+		{FQDN: "c.example.com", Protocol: "tcp", Port: "080"},
+		{FQDN: "*", Protocol: "all", Port: "all"},
+		{FQDN: "*", Protocol: "all"},
+	}
+	_, err := ValidateFQDNFilters(filters)
+	errs, ok := err.(FQDNFilterErrors)
+	assert.Equal(t, true, ok)
+	if assert.Equal(t, 4, len(errs)) {
+		assert.Equal(t, 1, errs[0].Index)
+		assert.Equal(t, 2, errs[1].Index)
+		assert.Equal(t, 4, errs[2].Index)
+		assert.Equal(t, 6, errs[3].Index)
+	}
+
+	normalized, err := ValidateFQDNFilters(filters[3:4])
+	assert.Nil(t, err)
+	assert.Equal(t, "c.example.com", normalized[0].FQDN)
+}
@@ -209,7 +209,8 @@ func (c *Client) DisableHaGateway(gateway *Gateway) error {
 	return nil
 }
 
-func (c *Client) GetGateway(gateway *Gateway) (*Gateway, error) {
+// ListGateways returns every gateway known to the controller.
+func (c *Client) ListGateways() ([]Gateway, error) {
 	url := "?CID=%s&action=list_vpcs_summary"
 	path := c.baseURL + fmt.Sprintf(url, c.CID)
 
@@ -225,8 +226,14 @@ func (c *Client) GetGateway(gateway *Gateway) (*Gateway, error) {
 	if !data.Return {
 		return nil, errors.New(data.Reason)
 	}
+	return data.Results, nil
+}
 
-	gwlist := data.Results
+func (c *Client) GetGateway(gateway *Gateway) (*Gateway, error) {
+	gwlist, err := c.ListGateways()
+	if err != nil {
+		return nil, err
+	}
 	for i := range gwlist {
 		if gwlist[i].GwName == gateway.GwName {
 			return &gwlist[i], nil
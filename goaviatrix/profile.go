@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 	"strings"
 )
 
@@ -37,6 +38,12 @@ type ProfilePolicyListResp struct {
 	Reason  string        `json:"reason"`
 }
 
+type ProfileBasePolicyResp struct {
+	Return  bool   `json:"return"`
+	Results string `json:"results"`
+	Reason  string `json:"reason"`
+}
+
 type ProfileUserListResp struct {
 	Return  bool                `json:"return"`
 	Results map[string][]string `json:"results"`
@@ -44,24 +51,15 @@ type ProfileUserListResp struct {
 }
 
 func (c *Client) CreateProfile(profile *Profile) error {
-	path := c.baseURL + fmt.Sprintf("?CID=%s&action=add_user_profile&profile_name=%s&base_policy=%s",
-		c.CID, profile.Name, profile.BaseRule)
-	resp, err := c.Get(path, nil)
-	if err != nil {
+	if err := c.addUserProfile(profile); err != nil {
 		return err
 	}
 	var data APIResp
-	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
-	}
-	if !data.Return {
-		return errors.New(data.Reason)
-	}
 	policyStr, _ := json.Marshal(profile.Policy)
-	path = c.baseURL + fmt.Sprintf("?CID=%s&action=update_profile_policy&profile_name=%s&policy=%s",
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=update_profile_policy&profile_name=%s&policy=%s",
 		c.CID, profile.Name, policyStr)
 	log.Printf("[INFO] Creating Aviatrix Profile with Policy: %v", path)
-	resp, err = c.Get(path, nil)
+	resp, err := c.Get(path, nil)
 	if err != nil {
 		return err
 	}
@@ -73,19 +71,6 @@ func (c *Client) CreateProfile(profile *Profile) error {
 		return errors.New(data.Reason)
 	}
 
-	path = c.baseURL + fmt.Sprintf("?CID=%s&action=update_profile_policy&profile_name=%s&policy=%s",
-		c.CID, profile.Name, policyStr)
-	resp, err = c.Get(path, nil)
-	if err != nil {
-		return err
-	}
-
-	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
-		return err
-	}
-	if !data.Return {
-		return errors.New(data.Reason)
-	}
 	for _, user := range profile.UserList {
 		path = c.baseURL + fmt.Sprintf("?CID=%s&action=add_profile_member&profile_name=%s&username=%s",
 			c.CID, profile.Name, user)
@@ -104,6 +89,24 @@ func (c *Client) CreateProfile(profile *Profile) error {
 	return nil
 }
 
+// addUserProfile creates an empty profile with its base policy only.
+func (c *Client) addUserProfile(profile *Profile) error {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=add_user_profile&profile_name=%s&base_policy=%s",
+		c.CID, profile.Name, profile.BaseRule)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return err
+	}
+	var data APIResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+	if !data.Return {
+		return errors.New(data.Reason)
+	}
+	return nil
+}
+
 func (c *Client) GetProfile(profile *Profile) (*Profile, error) {
 	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_profile_policies&profile_name=%s", c.CID, profile.Name)
 	resp, err := c.Get(path, nil)
@@ -126,24 +129,36 @@ func (c *Client) GetProfile(profile *Profile) (*Profile, error) {
 	profile.Policy = data.Results
 	log.Printf("[TRACE] Profile policy %s", profile.Policy)
 
-	path = c.baseURL + fmt.Sprintf("?CID=%s&action=list_user_profile_names", c.CID)
-	resp, err = c.Get(path, nil)
-
+	members, err := c.ListProfileMembers()
 	if err != nil {
 		return nil, err
 	}
-	var data2 ProfileUserListResp
-	if err = json.NewDecoder(resp.Body).Decode(&data2); err != nil {
-		return nil, err
-	}
 
-	profile.UserList = data2.Results[profile.Name]
+	profile.UserList = members[profile.Name]
 
 	log.Printf("[TRACE] Profile list of users %s", profile.UserList)
 
 	return profile, nil
 }
 
+// ListProfileMembers returns the users attached to every profile, keyed by
+// profile name.
+func (c *Client) ListProfileMembers() (map[string][]string, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_user_profile_names", c.CID)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data ProfileUserListResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		return nil, errors.New(data.Reason)
+	}
+	return data.Results, nil
+}
+
 func (c *Client) UpdateProfilePolicy(profile *Profile) error {
 	log.Printf("[TRACE] Updating Profile Policy %#v", profile)
 	policyStr, _ := json.Marshal(profile.Policy)
@@ -166,11 +181,51 @@ func (c *Client) UpdateProfilePolicy(profile *Profile) error {
 
 }
 
+// GetProfileBasePolicy reads the base policy of a profile, "allow_all" or
+// "deny_all", into profile.BaseRule.
+func (c *Client) GetProfileBasePolicy(profile *Profile) (*Profile, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=get_profile_base_policy&profile_name=%s", c.CID, profile.Name)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data ProfileBasePolicyResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		if strings.Contains(data.Reason, "does not exist") {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New(data.Reason)
+	}
+	profile.BaseRule = data.Results
+	return profile, nil
+}
+
+func (c *Client) UpdateProfileBasePolicy(profile *Profile) error {
+	log.Printf("[TRACE] Updating Profile base policy %s to %s", profile.Name, profile.BaseRule)
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=update_profile_base_policy&profile_name=%s&base_policy=%s",
+		c.CID, profile.Name, profile.BaseRule)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return err
+	}
+	var data APIResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+	if !data.Return {
+		return errors.New(data.Reason)
+	}
+	return nil
+}
+
 func (c *Client) AttachUsers(profile *Profile) error {
 	log.Printf("[TRACE] Attaching users %s", profile.UserList)
 	path := c.baseURL + fmt.Sprintf("?CID=%s&action=add_profile_member&profile_name=%s", c.CID, profile.Name)
 	for i := range profile.UserList {
-		newPath := path + fmt.Sprintf("&username=%s", profile.UserList[i])
+		newPath := path + fmt.Sprintf("&username=%s", url.QueryEscape(profile.UserList[i]))
 		resp, err := c.Get(newPath, nil)
 		if err != nil {
 			return err
@@ -190,7 +245,7 @@ func (c *Client) DetachUsers(profile *Profile) error {
 	log.Printf("[TRACE] Attaching users %s", profile.UserList)
 	path := c.baseURL + fmt.Sprintf("?CID=%s&action=del_profile_member&profile_name=%s", c.CID, profile.Name)
 	for i := range profile.UserList {
-		newPath := path + fmt.Sprintf("&username=%s", profile.UserList[i])
+		newPath := path + fmt.Sprintf("&username=%s", url.QueryEscape(profile.UserList[i]))
 		resp, err := c.Get(newPath, nil)
 		if err != nil {
 			return err
@@ -0,0 +1,256 @@
+package goaviatrix
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// ProfileChangeReport describes the operations needed to bring a VPN user
+// profile from its current state to the desired one, as returned by
+// PlanProfile and ApplyProfile.
+type ProfileChangeReport struct {
+	ProfileName    string
+	Create         bool
+	BaseRuleChange string
+	RulesAdded     []ProfileRule
+	RulesRemoved   []ProfileRule
+	PolicyChanged  bool
+	UsersAttached  []string
+	UsersDetached  []string
+	Applied        bool
+	RolledBack     bool
+
+	current *Profile
+}
+
+// HasChanges reports whether the plan contains any operation.
+func (r *ProfileChangeReport) HasChanges() bool {
+	return r.Create || r.BaseRuleChange != "" || r.PolicyChanged ||
+		len(r.UsersAttached) != 0 || len(r.UsersDetached) != 0
+}
+
+// String renders the plan one operation per line, for use in change reviews.
+// A rule list that only changed order is shown as "~ policy order".
+func (r *ProfileChangeReport) String() string {
+	var b strings.Builder
+	if r.Create {
+		fmt.Fprintf(&b, "+ profile %s\n", r.ProfileName)
+	}
+	if r.BaseRuleChange != "" {
+		fmt.Fprintf(&b, "~ base policy %s\n", r.BaseRuleChange)
+	}
+	for _, rule := range r.RulesRemoved {
+		fmt.Fprintf(&b, "- rule %s\n", profileRuleKey(rule))
+	}
+	for _, rule := range r.RulesAdded {
+		fmt.Fprintf(&b, "+ rule %s\n", profileRuleKey(rule))
+	}
+	if r.PolicyChanged && len(r.RulesAdded) == 0 && len(r.RulesRemoved) == 0 {
+		b.WriteString("~ policy order\n")
+	}
+	for _, user := range r.UsersDetached {
+		fmt.Fprintf(&b, "- user %s\n", user)
+	}
+	for _, user := range r.UsersAttached {
+		fmt.Fprintf(&b, "+ user %s\n", user)
+	}
+	return b.String()
+}
+
+// PlanProfile reads the current state of profile desired.Name and returns the
+// changes ApplyProfile would make, without making them. The rule list and the
+// user list are treated as complete; an empty BaseRule leaves the base policy
+// untouched, and is rejected when the profile has to be created.
+func (c *Client) PlanProfile(ctx context.Context, desired *Profile) (*ProfileChangeReport, error) {
+	if desired.Name == "" {
+		return nil, errors.New("profile name is required")
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	current, err := c.GetProfile(&Profile{Name: desired.Name})
+	if err == ErrNotFound {
+		if desired.BaseRule == "" {
+			return nil, fmt.Errorf("base rule is required to create profile %s", desired.Name)
+		}
+		return diffProfile(nil, desired), nil
+	} else if err != nil {
+		return nil, err
+	}
+	if desired.BaseRule != "" {
+		if _, err := c.GetProfileBasePolicy(current); err != nil {
+			return nil, err
+		}
+	}
+	return diffProfile(current, desired), nil
+}
+
+// ApplyProfile reconciles a VPN user profile with desired, creating it if
+// needed, and returns the changes made. If a step fails, the steps already
+// made are undone: a profile created by this call is deleted again, and an
+// existing profile gets its previous base policy, rules and users back. Every
+// undo is attempted even if an earlier one fails. The report's RolledBack
+// field records whether the whole rollback succeeded; the errors of failed
+// undos are included in the returned error.
+func (c *Client) ApplyProfile(ctx context.Context, desired *Profile) (*ProfileChangeReport, error) {
+	report, err := c.PlanProfile(ctx, desired)
+	if err != nil {
+		return nil, err
+	}
+	if !report.HasChanges() {
+		return report, nil
+	}
+
+	name := desired.Name
+	current := report.current
+	type step struct {
+		apply func() error
+		undo  func() error
+	}
+	steps := make([]step, 0)
+	if report.Create {
+		// the profile and its rules are separate steps, so a failed rule
+		// update still deletes the new profile
+		steps = append(steps, step{
+			apply: func() error { return c.addUserProfile(&Profile{Name: name, BaseRule: desired.BaseRule}) },
+			undo:  func() error { return c.DeleteProfile(&Profile{Name: name}) },
+		}, step{
+			apply: func() error {
+				return c.UpdateProfilePolicy(&Profile{Name: name, Policy: profilePolicy(desired.Policy)})
+			},
+			undo: func() error { return nil },
+		})
+	} else {
+		if report.BaseRuleChange != "" {
+			steps = append(steps, step{
+				apply: func() error {
+					return c.UpdateProfileBasePolicy(&Profile{Name: name, BaseRule: report.BaseRuleChange})
+				},
+				undo: func() error {
+					return c.UpdateProfileBasePolicy(&Profile{Name: name, BaseRule: current.BaseRule})
+				},
+			})
+		}
+		if report.PolicyChanged {
+			steps = append(steps, step{
+				apply: func() error {
+					return c.UpdateProfilePolicy(&Profile{Name: name, Policy: profilePolicy(desired.Policy)})
+				},
+				undo: func() error {
+					return c.UpdateProfilePolicy(&Profile{Name: name, Policy: profilePolicy(current.Policy)})
+				},
+			})
+		}
+	}
+	for _, user := range report.UsersDetached {
+		user := user
+		steps = append(steps, step{
+			apply: func() error { return c.DetachUsers(&Profile{Name: name, UserList: []string{user}}) },
+			undo:  func() error { return c.AttachUsers(&Profile{Name: name, UserList: []string{user}}) },
+		})
+	}
+	for _, user := range report.UsersAttached {
+		user := user
+		steps = append(steps, step{
+			apply: func() error { return c.AttachUsers(&Profile{Name: name, UserList: []string{user}}) },
+			undo:  func() error { return c.DetachUsers(&Profile{Name: name, UserList: []string{user}}) },
+		})
+	}
+
+	for i, s := range steps {
+		err := ctx.Err()
+		if err == nil {
+			err = s.apply()
+		}
+		if err == nil {
+			continue
+		}
+		undo := steps[:i]
+		if report.Create && i > 0 {
+			// deleting the new profile undoes everything done to it
+			undo = steps[:1]
+		}
+		log.Printf("[INFO] Rolling back %d changes to profile %s: %v", len(undo), name, err)
+		var rbErrs []string
+		for k := len(undo) - 1; k >= 0; k-- {
+			if rbErr := undo[k].undo(); rbErr != nil {
+				rbErrs = append(rbErrs, rbErr.Error())
+			}
+		}
+		if len(rbErrs) != 0 {
+			return report, fmt.Errorf("%v (rollback failed: %s)", err, strings.Join(rbErrs, "; "))
+		}
+		report.RolledBack = true
+		return report, err
+	}
+	report.Applied = true
+	return report, nil
+}
+
+// diffProfile computes the change report between current and desired. A nil
+// current means the profile does not exist yet.
+func diffProfile(current, desired *Profile) *ProfileChangeReport {
+	report := &ProfileChangeReport{ProfileName: desired.Name, current: current}
+	if current == nil {
+		report.Create = true
+		current = &Profile{Name: desired.Name}
+	} else if desired.BaseRule != "" && desired.BaseRule != current.BaseRule {
+		report.BaseRuleChange = desired.BaseRule
+	}
+
+	currentRules := make(map[string]bool)
+	for _, rule := range current.Policy {
+		currentRules[profileRuleKey(rule)] = true
+	}
+	desiredRules := make(map[string]bool)
+	for _, rule := range desired.Policy {
+		key := profileRuleKey(rule)
+		if !desiredRules[key] && !currentRules[key] {
+			report.RulesAdded = append(report.RulesAdded, rule)
+		}
+		desiredRules[key] = true
+	}
+	for _, rule := range current.Policy {
+		if !desiredRules[profileRuleKey(rule)] {
+			report.RulesRemoved = append(report.RulesRemoved, rule)
+		}
+	}
+	if len(current.Policy) != len(desired.Policy) {
+		report.PolicyChanged = true
+	} else {
+		for i := range current.Policy {
+			if profileRuleKey(current.Policy[i]) != profileRuleKey(desired.Policy[i]) {
+				report.PolicyChanged = true
+				break
+			}
+		}
+	}
+	if report.Create {
+		// the rules are set when the profile is created
+		report.PolicyChanged = false
+	}
+
+	report.UsersAttached = Difference(desired.UserList, current.UserList)
+	report.UsersDetached = Difference(current.UserList, desired.UserList)
+	sort.Strings(report.UsersAttached)
+	sort.Strings(report.UsersDetached)
+	return report
+}
+
+// profilePolicy returns policy, or an empty list in place of nil so that
+// update_profile_policy clears the rules instead of receiving "null".
+func profilePolicy(policy []ProfileRule) []ProfileRule {
+	if policy == nil {
+		return []ProfileRule{}
+	}
+	return policy
+}
+
+func profileRuleKey(rule ProfileRule) string {
+	return fmt.Sprintf("%s %s %s %s", strings.ToLower(rule.Action), strings.ToLower(rule.Protocol),
+		rule.Target, rule.Port)
+}
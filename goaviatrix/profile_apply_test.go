@@ -0,0 +1,183 @@
+package goaviatrix
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffProfile(t *testing.T) {
+	web := ProfileRule{Action: "allow", Protocol: "tcp", Target: "10.0.0.0/16", Port: "443"}
+	ssh := ProfileRule{Action: "allow", Protocol: "tcp", Target: "10.0.0.0/16", Port: "22"}
+	dns := ProfileRule{Action: "allow", Protocol: "udp", Target: "10.0.0.2/32", Port: "53"}
+
+	report := diffProfile(nil, &Profile{Name: "dev", BaseRule: "deny_all", Policy: []ProfileRule{web}, UserList: []string{"bob", "alice"}})
+	assert.Equal(t, true, report.Create)
+	assert.Equal(t, false, report.PolicyChanged)
+	assert.Equal(t, []string{"alice", "bob"}, report.UsersAttached)
+	assert.Equal(t, "+ profile dev\n"+
+		"+ rule allow tcp 10.0.0.0/16 443\n"+
+		"+ user alice\n"+
+		"+ user bob\n", report.String())
+
+	current := &Profile{Name: "dev", BaseRule: "deny_all", Policy: []ProfileRule{web, ssh}, UserList: []string{"alice", "carol"}}
+	report = diffProfile(current, &Profile{Name: "dev", BaseRule: "allow_all", Policy: []ProfileRule{web, dns}, UserList: []string{"alice", "bob"}})
+	assert.Equal(t, false, report.Create)
+	assert.Equal(t, "allow_all", report.BaseRuleChange)
+	assert.Equal(t, true, report.PolicyChanged)
+	assert.Equal(t, []ProfileRule{dns}, report.RulesAdded)
+	assert.Equal(t, []ProfileRule{ssh}, report.RulesRemoved)
+	assert.Equal(t, []string{"bob"}, report.UsersAttached)
+	assert.Equal(t, []string{"carol"}, report.UsersDetached)
+
+	report = diffProfile(current, &Profile{Name: "dev", Policy: []ProfileRule{ssh, web}, UserList: []string{"carol", "alice"}})
+	assert.Equal(t, true, report.HasChanges())
+	assert.Equal(t, "~ policy order\n", report.String())
+
+	report = diffProfile(current, &Profile{Name: "dev", BaseRule: "deny_all", Policy: []ProfileRule{web, ssh}, UserList: []string{"alice", "carol"}})
+	assert.Equal(t, false, report.HasChanges())
+}
+
+func TestApplyProfileRollback(t *testing.T) {
+	calls := make([]string, 0)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_profile_policies":
+			w.Write([]byte(`{"return": false, "reason": "Profile qa does not exist"}`))
+		case "add_profile_member":
+			calls = append(calls, action+" "+r.Form.Get("username"))
+			if r.Form.Get("username") == "bob" {
+				w.Write([]byte(`{"return": false, "reason": "User bob does not exist"}`))
+				return
+			}
+			w.Write([]byte(apiSuccess))
+		default:
+			calls = append(calls, action)
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	report, err := client.ApplyProfile(context.Background(), &Profile{
+		Name:     "qa",
+		BaseRule: "deny_all",
+		UserList: []string{"alice", "bob"},
+	})
+	assert.Error(t, err)
+	assert.Equal(t, "User bob does not exist", err.Error())
+	assert.Equal(t, true, report.RolledBack)
+	assert.Equal(t, false, report.Applied)
+	assert.Equal(t, []string{
+		"add_user_profile",
+		"update_profile_policy",
+		"add_profile_member alice",
+		"add_profile_member bob",
+		"del_user_profile",
+	}, calls)
+}
+
+func TestApplyProfileRollbackPolicy(t *testing.T) {
+	calls := make([]string, 0)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_profile_policies":
+			w.Write([]byte(`{"return": false, "reason": "Profile qa does not exist"}`))
+		case "update_profile_policy":
+			calls = append(calls, action)
+			w.Write([]byte(`{"return": false, "reason": "Invalid port 70000"}`))
+		default:
+			calls = append(calls, action)
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	report, err := client.ApplyProfile(context.Background(), &Profile{
+		Name:     "qa",
+		BaseRule: "deny_all",
+		Policy:   []ProfileRule{{Action: "allow", Protocol: "tcp", Target: "10.0.0.0/8", Port: "70000"}},
+		UserList: []string{"alice"},
+	})
+	if assert.Error(t, err) {
+		assert.Equal(t, "Invalid port 70000", err.Error())
+	}
+	assert.Equal(t, true, report.RolledBack)
+	assert.Equal(t, []string{"add_user_profile", "update_profile_policy", "del_user_profile"}, calls)
+}
+
+func TestApplyProfileRollbackErrors(t *testing.T) {
+	calls := make([]string, 0)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		action := r.Form.Get("action")
+		switch action {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_profile_policies":
+			if r.Form.Get("profile_name") == "qa" {
+				w.Write([]byte(`{"return": false, "reason": "Profile qa does not exist"}`))
+				return
+			}
+			w.Write([]byte(`{"return": true, "results": []}`))
+		case "list_user_profile_names":
+			w.Write([]byte(`{"return": true, "results": {"dev": ["carol"]}}`))
+		case "add_profile_member", "del_profile_member":
+			user := r.Form.Get("username")
+			calls = append(calls, action+" "+user)
+			if action == "add_profile_member" && user == "bob" || action == "del_profile_member" && user == "alice" {
+				w.Write([]byte(`{"return": false, "reason": "failed for ` + user + `"}`))
+				return
+			}
+			w.Write([]byte(apiSuccess))
+		default:
+			calls = append(calls, action)
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	// the undos after a failed one still run
+	report, err := client.ApplyProfile(context.Background(), &Profile{Name: "dev", UserList: []string{"alice", "bob"}})
+	if assert.Error(t, err) {
+		assert.Equal(t, "failed for bob (rollback failed: failed for alice)", err.Error())
+	}
+	assert.Equal(t, false, report.RolledBack)
+	assert.Equal(t, []string{
+		"del_profile_member carol",
+		"add_profile_member alice",
+		"add_profile_member bob",
+		"del_profile_member alice",
+		"add_profile_member carol",
+	}, calls)
+
+	calls = calls[:0]
+	_, err = client.PlanProfile(context.Background(), &Profile{Name: "qa", UserList: []string{"alice"}})
+	if assert.Error(t, err) {
+		assert.Equal(t, "base rule is required to create profile qa", err.Error())
+	}
+	_, err = client.ApplyProfile(context.Background(), &Profile{Name: "qa", UserList: []string{"alice"}})
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(calls))
+}
@@ -0,0 +1,155 @@
+package goaviatrix
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ProfileVerdict is the result of evaluating a flow against one profile.
+// RuleIndex is the position of the matching rule in Profile.Policy, or -1
+// when the flow fell through to the profile's base policy, in which case Rule
+// is nil.
+type ProfileVerdict struct {
+	Profile   string
+	Allowed   bool
+	RuleIndex int
+	Rule      *ProfileRule
+}
+
+func (v *ProfileVerdict) String() string {
+	action := "denied"
+	if v.Allowed {
+		action = "allowed"
+	}
+	if v.RuleIndex < 0 {
+		return fmt.Sprintf("profile %s: %s by base policy", v.Profile, action)
+	}
+	return fmt.Sprintf("profile %s: %s by rule %d (%s)", v.Profile, action, v.RuleIndex, profileRuleKey(*v.Rule))
+}
+
+// UserAccessVerdict is the result of EvaluateUserAccess. Profiles holds one
+// verdict per profile the user is attached to, in the order the profiles were
+// given.
+type UserAccessVerdict struct {
+	UserName string
+	Allowed  bool
+	Profiles []ProfileVerdict
+}
+
+// String explains the verdict, one line per profile, for use in helpdesk
+// replies.
+func (v *UserAccessVerdict) String() string {
+	var b strings.Builder
+	action := "denied"
+	if v.Allowed {
+		action = "allowed"
+	}
+	if len(v.Profiles) == 0 {
+		fmt.Fprintf(&b, "user %s: %s, not attached to any profile\n", v.UserName, action)
+		return b.String()
+	}
+	fmt.Fprintf(&b, "user %s: %s\n", v.UserName, action)
+	for i := range v.Profiles {
+		fmt.Fprintf(&b, "  %s\n", v.Profiles[i].String())
+	}
+	return b.String()
+}
+
+// EvaluateProfile runs flow through the profile's rules in order and returns
+// the first match, or the profile's base policy if no rule matches. Only the
+// flow's DstIP, Protocol and Port are used; a profile rule's target is an IPv4
+// address or CIDR, its protocol "all" or a protocol name, and its port a
+// single port, a range such as "1024:65535" or "all".
+func EvaluateProfile(profile *Profile, flow *Flow) (*ProfileVerdict, error) {
+	dst, err := parseFlowIP(flow.DstIP)
+	if err != nil {
+		return nil, err
+	}
+	protocol := strings.ToLower(flow.Protocol)
+	if protocol == "" {
+		return nil, errors.New("flow protocol is required")
+	}
+
+	for i := range profile.Policy {
+		rule := &profile.Policy[i]
+		matched, err := profileRuleMatches(rule, dst, protocol, flow.Port)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s rule %d: %v", profile.Name, i, err)
+		}
+		if matched {
+			return &ProfileVerdict{
+				Profile:   profile.Name,
+				Allowed:   strings.ToLower(rule.Action) == "allow",
+				RuleIndex: i,
+				Rule:      rule,
+			}, nil
+		}
+	}
+
+	switch strings.ToLower(profile.BaseRule) {
+	case "allow_all":
+		return &ProfileVerdict{Profile: profile.Name, Allowed: true, RuleIndex: -1}, nil
+	case "deny_all":
+		return &ProfileVerdict{Profile: profile.Name, Allowed: false, RuleIndex: -1}, nil
+	}
+	return nil, fmt.Errorf("profile %s has unknown base policy %q", profile.Name, profile.BaseRule)
+}
+
+// EvaluateUserAccess reports whether userName may reach flow.DstIP on the
+// given protocol and port. The user's profiles are the ones in profiles whose
+// UserList contains the user. Each is evaluated with EvaluateProfile and the
+// access is allowed if any of them allows it, as the controller grants a user
+// the union of its profiles. A user attached to no profile is not restricted.
+func EvaluateUserAccess(profiles []*Profile, userName string, flow *Flow) (*UserAccessVerdict, error) {
+	verdict := &UserAccessVerdict{
+		UserName: userName,
+		Profiles: make([]ProfileVerdict, 0),
+	}
+	for _, profile := range profiles {
+		if len(Difference([]string{userName}, profile.UserList)) != 0 {
+			continue
+		}
+		result, err := EvaluateProfile(profile, flow)
+		if err != nil {
+			return nil, err
+		}
+		verdict.Profiles = append(verdict.Profiles, *result)
+		if result.Allowed {
+			verdict.Allowed = true
+		}
+	}
+	if len(verdict.Profiles) == 0 {
+		verdict.Allowed = true
+	}
+	return verdict, nil
+}
+
+func profileRuleMatches(rule *ProfileRule, dst uint32, protocol string, port int) (bool, error) {
+	action := strings.ToLower(rule.Action)
+	if action != "allow" && action != "deny" {
+		return false, fmt.Errorf("action must be allow or deny, got %q", rule.Action)
+	}
+	target := strings.TrimSpace(rule.Target)
+	r, ok, err := parseIPv4Range(target)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, fmt.Errorf("invalid target %q", rule.Target)
+	}
+	ruleProtocol := strings.ToLower(strings.TrimSpace(rule.Protocol))
+	if ruleProtocol != "" && ruleProtocol != "all" && ruleProtocol != protocol {
+		return false, nil
+	}
+	if ruleProtocol != "icmp" && protocol != "icmp" {
+		ports, err := parsePortSet(rule.Port)
+		if err != nil {
+			return false, err
+		}
+		if !ports.contains(port) {
+			return false, nil
+		}
+	}
+	return r.lo <= dst && dst <= r.hi, nil
+}
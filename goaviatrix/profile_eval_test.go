@@ -0,0 +1,70 @@
+package goaviatrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluateUserAccess(t *testing.T) {
+	profiles := []*Profile{
+		{
+			Name:     "dev",
+			BaseRule: "deny_all",
+			UserList: []string{"alice", "bob"},
+			Policy: []ProfileRule{
+				{Action: "deny", Protocol: "tcp", Target: "10.0.5.0/24", Port: "22"},
+				{Action: "allow", Protocol: "tcp", Target: "10.0.0.0/16", Port: "0:65535"},
+				{Action: "allow", Protocol: "icmp", Target: "10.0.0.0/16"},
+			},
+		},
+		{
+			Name:     "ops",
+			BaseRule: "deny_all",
+			UserList: []string{"bob"},
+			Policy: []ProfileRule{
+				{Action: "allow", Protocol: "all", Target: "10.0.5.0/24", Port: "all"},
+			},
+		},
+	}
+	tests := []struct {
+		name     string
+		user     string
+		flow     Flow
+		allowed  bool
+		profiles int
+	}{
+		{"first rule denies", "alice", Flow{DstIP: "10.0.5.9", Protocol: "tcp", Port: 22}, false, 1},
+		{"second rule allows", "alice", Flow{DstIP: "10.0.5.9", Protocol: "tcp", Port: 443}, true, 1},
+		{"icmp", "alice", Flow{DstIP: "10.0.1.1", Protocol: "icmp"}, true, 1},
+		{"base policy", "alice", Flow{DstIP: "8.8.8.8", Protocol: "udp", Port: 53}, false, 1},
+		{"other profile allows", "bob", Flow{DstIP: "10.0.5.9", Protocol: "tcp", Port: 22}, true, 2},
+		{"no profile", "carol", Flow{DstIP: "8.8.8.8", Protocol: "udp", Port: 53}, true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			verdict, err := EvaluateUserAccess(profiles, tt.user, &tt.flow)
+			assert.Nil(t, err)
+			assert.Equal(t, tt.allowed, verdict.Allowed)
+			assert.Equal(t, tt.profiles, len(verdict.Profiles))
+		})
+	}
+
+	verdict, err := EvaluateUserAccess(profiles, "bob", &Flow{DstIP: "10.0.5.9", Protocol: "tcp", Port: 22})
+	assert.Nil(t, err)
+	assert.Equal(t, "user bob: allowed\n"+
+		"  profile dev: denied by rule 0 (deny tcp 10.0.5.0/24 22)\n"+
+		"  profile ops: allowed by rule 0 (allow all 10.0.5.0/24 all)\n", verdict.String())
+}
+
+func TestEvaluateProfileErrors(t *testing.T) {
+	flow := &Flow{DstIP: "10.0.0.1", Protocol: "tcp", Port: 80}
+	_, err := EvaluateProfile(&Profile{Name: "p", BaseRule: "maybe"}, flow)
+	assert.Error(t, err)
+	_, err = EvaluateProfile(&Profile{Name: "p", BaseRule: "deny_all", Policy: []ProfileRule{
+		{Action: "allow", Protocol: "tcp", Target: "web-servers", Port: "80"},
+	}}, flow)
+	assert.Error(t, err)
+	_, err = EvaluateProfile(&Profile{Name: "p", BaseRule: "deny_all"}, &Flow{DstIP: "nope", Protocol: "tcp"})
+	assert.Error(t, err)
+}
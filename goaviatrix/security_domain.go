@@ -14,6 +14,10 @@ type SecurityDomain struct {
 	AccountName string `form:"account_name, omitempty"`
 	Region      string `form:"region, omitempty"`
 	AwsTgwName  string `form:"tgw_name, omitempty"`
+
+	FirewallDomain       bool `form:"firewall_domain,omitempty"`
+	NativeEgressDomain   bool `form:"native_egress_domain,omitempty"`
+	NativeFirewallDomain bool `form:"native_firewall_domain,omitempty"`
 }
 
 type SecurityDomainAPIResp struct {
@@ -32,6 +36,10 @@ type SecurityDomainRule struct {
 	Name            string    `json:"security_domain_name, omitempty"`
 	ConnectedDomain []string  `json:"connected_domains, omitempty"`
 	AttachedVPCs    []VPCSolo `json:"attached_vpc, omitempty"`
+
+	FirewallDomain       bool `json:"firewall_domain,omitempty"`
+	NativeEgressDomain   bool `json:"native_egress_domain,omitempty"`
+	NativeFirewallDomain bool `json:"native_firewall_domain,omitempty"`
 }
 
 type VPCSolo struct {
@@ -41,6 +49,10 @@ type VPCSolo struct {
 }
 
 func (c *Client) CreateSecurityDomain(securityDomain *SecurityDomain) error {
+	if err := validateSecurityDomainFlags(securityDomain.Name, securityDomain.FirewallDomain,
+		securityDomain.NativeEgressDomain, securityDomain.NativeFirewallDomain); err != nil {
+		return err
+	}
 	securityDomain.CID = c.CID
 	securityDomain.Action = "add_route_domain"
 	resp, err := c.Post(c.baseURL, securityDomain)
@@ -88,10 +100,71 @@ func (c *Client) GetSecurityDomain(securityDomain *SecurityDomain) (string, erro
 	return "", ErrNotFound
 }
 
+// ListSecurityDomains returns every security domain of awsTgw.Name with its
+// connected domains, attached VPCs and flags. The Aviatrix transit gateways
+// attached to Aviatrix_Edge_Domain are not listed as VPCs.
+func (c *Client) ListSecurityDomains(awsTgw *AWSTgw) ([]SecurityDomainRule, error) {
+	names, err := c.listRouteDomainNames(awsTgw.Name)
+	if err != nil {
+		return nil, err
+	}
+	details, err := c.getRouteDomainDetails(awsTgw.Name, names)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]SecurityDomainRule, 0, len(details))
+	for _, detail := range details {
+		rules = append(rules, newSecurityDomainRule(detail))
+	}
+	return rules, nil
+}
+
+// GetSecurityDomainRule returns the security domain securityDomain.Name of
+// securityDomain.AwsTgwName with its connected domains, attached VPCs and
+// flags. ErrNotFound is returned if the domain does not exist.
+func (c *Client) GetSecurityDomainRule(securityDomain *SecurityDomain) (*SecurityDomainRule, error) {
+	names, err := c.listRouteDomainNames(securityDomain.AwsTgwName)
+	if err != nil {
+		return nil, err
+	}
+	if !containsString(names, securityDomain.Name) {
+		return nil, ErrNotFound
+	}
+	detail, err := c.getRouteDomainDetail(securityDomain.AwsTgwName, securityDomain.Name)
+	if err != nil {
+		return nil, err
+	}
+	rule := newSecurityDomainRule(detail)
+	return &rule, nil
+}
+
+// UpdateSecurityDomain checks the aviatrix firewall, native egress and native
+// firewall flags of securityDomain against the existing domain. The controller
+// has no API to change the flags of a domain, so a domain whose flags differ
+// must be deleted and created again; UpdateSecurityDomain returns an error
+// saying so rather than changing anything.
 func (c *Client) UpdateSecurityDomain(securityDomain *SecurityDomain) error {
+	if err := validateSecurityDomainFlags(securityDomain.Name, securityDomain.FirewallDomain,
+		securityDomain.NativeEgressDomain, securityDomain.NativeFirewallDomain); err != nil {
+		return err
+	}
+	rule, err := c.GetSecurityDomainRule(securityDomain)
+	if err != nil {
+		return err
+	}
+	if rule.FirewallDomain != securityDomain.FirewallDomain ||
+		rule.NativeEgressDomain != securityDomain.NativeEgressDomain ||
+		rule.NativeFirewallDomain != securityDomain.NativeFirewallDomain {
+		return domainFlagsChangeError(securityDomain.Name)
+	}
 	return nil
 }
 
+func domainFlagsChangeError(name string) error {
+	return fmt.Errorf("changing the flags of security domain %s is not supported; the domain must be recreated",
+		name)
+}
+
 func (c *Client) DeleteSecurityDomain(securityDomain *SecurityDomain) error {
 	securityDomain.CID = c.CID
 	securityDomain.Action = "delete_route_domain"
@@ -150,3 +223,52 @@ func (c *Client) DeleteDomainConnection(awsTgw *AWSTgw, sourceDomain string, des
 
 	return nil
 }
+
+// newSecurityDomainRule converts a view_route_domain_details result. VPCs
+// attached to Aviatrix_Edge_Domain are transit gateways and are left out.
+func newSecurityDomainRule(detail *RouteDomainDetail) SecurityDomainRule {
+	sdr := SecurityDomainRule{
+		Name:                 detail.Name,
+		FirewallDomain:       detail.FirewallDomain,
+		NativeEgressDomain:   detail.NativeEgressDomain,
+		NativeFirewallDomain: detail.NativeFirewallDomain,
+	}
+	for i := range detail.ConnectedRouteDomain {
+		sdr.ConnectedDomain = append(sdr.ConnectedDomain, detail.ConnectedRouteDomain[i])
+	}
+	if detail.Name == "Aviatrix_Edge_Domain" {
+		return sdr
+	}
+	for _, attachedVPC := range detail.AttachedVPC {
+		sdr.AttachedVPCs = append(sdr.AttachedVPCs, VPCSolo{
+			Region:      attachedVPC.Region,
+			AccountName: attachedVPC.AccountName,
+			VpcID:       attachedVPC.VPCId,
+		})
+	}
+	return sdr
+}
+
+// validateSecurityDomainFlags checks that at most one domain flag is set, and
+// none on a default domain.
+func validateSecurityDomainFlags(name string, firewall, nativeEgress, nativeFirewall bool) error {
+	set := 0
+	for _, flag := range []bool{firewall, nativeEgress, nativeFirewall} {
+		if flag {
+			set++
+		}
+	}
+	if set == 0 {
+		return nil
+	}
+	if set > 1 {
+		return fmt.Errorf("security domain %s can only be one of aviatrix firewall, native egress and native "+
+			"firewall domain", name)
+	}
+	for _, dflt := range defaultSecurityDomains {
+		if name == dflt {
+			return fmt.Errorf("default security domain %s cannot be a firewall or egress domain", name)
+		}
+	}
+	return nil
+}
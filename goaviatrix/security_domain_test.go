@@ -0,0 +1,56 @@
+package goaviatrix
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListSecurityDomains(t *testing.T) {
+	defer withFirewallDevDomain()()
+	client, teardown := awsTgwTestClient(t, nil)
+	defer teardown()
+
+	rules, err := client.ListSecurityDomains(&AWSTgw{Name: "tgw1"})
+	assert.Nil(t, err)
+	if !assert.Equal(t, 5, len(rules)) {
+		return
+	}
+	assert.Equal(t, SecurityDomainRule{Name: "Aviatrix_Edge_Domain",
+		ConnectedDomain: []string{"Default_Domain", "Shared_Service_Domain"}}, rules[0])
+	assert.Equal(t, SecurityDomainRule{Name: "prod", ConnectedDomain: []string{"Shared_Service_Domain"},
+		AttachedVPCs: []VPCSolo{{Region: "us-east-1", AccountName: "prod-acct", VpcID: "vpc-prod1"}}}, rules[3])
+	assert.Equal(t, SecurityDomainRule{Name: "dev", FirewallDomain: true}, rules[4])
+
+	rule, err := client.GetSecurityDomainRule(&SecurityDomain{Name: "dev", AwsTgwName: "tgw1"})
+	assert.Nil(t, err)
+	assert.True(t, rule.FirewallDomain)
+	_, err = client.GetSecurityDomainRule(&SecurityDomain{Name: "qa", AwsTgwName: "tgw1"})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestUpdateSecurityDomain(t *testing.T) {
+	defer withFirewallDevDomain()()
+	calls := make([]string, 0)
+	client, teardown := awsTgwTestClient(t, &calls)
+	defer teardown()
+
+	err := client.UpdateSecurityDomain(&SecurityDomain{Name: "dev", AwsTgwName: "tgw1", FirewallDomain: true})
+	assert.Nil(t, err)
+	err = client.UpdateSecurityDomain(&SecurityDomain{Name: "dev", AwsTgwName: "tgw1", NativeEgressDomain: true})
+	if assert.Error(t, err) {
+		assert.Equal(t, "changing the flags of security domain dev is not supported; the domain must be recreated",
+			err.Error())
+	}
+	assert.Equal(t, ErrNotFound, client.UpdateSecurityDomain(&SecurityDomain{Name: "qa", AwsTgwName: "tgw1"}))
+
+	err = client.UpdateSecurityDomain(&SecurityDomain{Name: "dev", AwsTgwName: "tgw1", FirewallDomain: true,
+		NativeFirewallDomain: true})
+	assert.Error(t, err)
+	err = client.UpdateSecurityDomain(&SecurityDomain{Name: "Default_Domain", AwsTgwName: "tgw1",
+		FirewallDomain: true})
+	assert.Error(t, err)
+	err = client.CreateSecurityDomain(&SecurityDomain{Name: "Shared_Service_Domain", NativeEgressDomain: true})
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(calls))
+}
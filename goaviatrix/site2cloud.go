@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 )
 
@@ -16,17 +18,151 @@ type Site2Cloud struct {
 	VpcID              string `form:"vpc_id,omitempty" json:"vpc_id,omitempty"`
 	TunnelName         string `form:"connection_name" json:"name,omitempty"`
 	RemoteGwType       string `form:"remote_gateway_type,omitempty" json:"peer_type,omitempty"`
-	ConnType           string `form:"connection_type,omitempty" json:""connection_type,omitempty"`
+	ConnType           string `form:"connection_type,omitempty" json:"connection_type,omitempty"`
 	TunnelType         string `form:"tunnel_type,omitempty" json:"tunnel_type,omitempty"`
 	GwName             string `form:"primary_cloud_gateway_name,omitempty" json:"gw_name,omitempty"`
-	BackupGwName       string `form:"backup_gateway_name,omitempty"`
+	BackupGwName       string `form:"backup_gateway_name,omitempty" json:"backup_gw_name,omitempty"`
 	RemoteGwIP         string `form:"remote_gateway_ip,omitempty" json:"peer_ip,omitempty"`
-	RemoteGwIP2        string `form:"backup_remote_gateway_ip,omitempty"`
+	RemoteGwIP2        string `form:"backup_remote_gateway_ip,omitempty" json:"backup_peer_ip,omitempty"`
 	PreSharedKey       string `form:"pre_shared_key,omitempty"`
 	BackupPreSharedKey string `form:"backup_pre_shared_key,omitempty"`
 	RemoteSubnet       string `form:"remote_subnet_cidr,omitempty" json:"remote_cidr,omitempty"`
 	LocalSubnet        string `form:"local_subnet_cidr,omitempty" json:"local_cidr,omitempty"`
 	HAEnabled          string `form:"ha_enabled,omitempty" json:"ha_status,omitempty"`
+
+	// IPsec parameters. Empty values leave the controller defaults in place.
+	IKEVersion       IKEVersion          `form:"ike_version,omitempty" json:"ike_version,omitempty"`
+	Phase1Encryption EncryptionAlgorithm `form:"phase1_encryption,omitempty" json:"ph1_encryption,omitempty"`
+	Phase1Auth       HashAlgorithm       `form:"phase1_auth,omitempty" json:"ph1_authentication,omitempty"`
+	Phase1DHGroup    DHGroup             `form:"phase1_dh_group,omitempty" json:"ph1_dh_group,omitempty"`
+	Phase2Encryption EncryptionAlgorithm `form:"phase2_encryption,omitempty" json:"ph2_encryption,omitempty"`
+	Phase2Auth       HashAlgorithm       `form:"phase2_auth,omitempty" json:"ph2_authentication,omitempty"`
+	Phase2DHGroup    DHGroup             `form:"phase2_dh_group,omitempty" json:"ph2_dh_group,omitempty"`
+
+	// NAT for mapped connections: the virtual subnets the local and remote
+	// subnets are translated to.
+	LocalSubnetVirtual  string `form:"virtual_local_subnet_cidr,omitempty" json:"local_cidr_virtual,omitempty"`
+	RemoteSubnetVirtual string `form:"virtual_remote_subnet_cidr,omitempty" json:"remote_cidr_virtual,omitempty"`
+
+	// Custom mapped subnets, used instead of the virtual subnets above when
+	// CustomMapped is "true". Each value is a comma separated CIDR list; the
+	// real and virtual lists of a pair must have the same length.
+	CustomMapped                  string `form:"custom_mapped,omitempty" json:"custom_mapped,omitempty"`
+	RemoteSourceRealCIDRs         string `form:"remote_source_real_cidrs,omitempty" json:"remote_src_real_cidrs,omitempty"`
+	RemoteSourceVirtualCIDRs      string `form:"remote_source_virtual_cidrs,omitempty" json:"remote_src_virtual_cidrs,omitempty"`
+	RemoteDestinationRealCIDRs    string `form:"remote_destination_real_cidrs,omitempty" json:"remote_dst_real_cidrs,omitempty"`
+	RemoteDestinationVirtualCIDRs string `form:"remote_destination_virtual_cidrs,omitempty" json:"remote_dst_virtual_cidrs,omitempty"`
+	LocalSourceRealCIDRs          string `form:"local_source_real_cidrs,omitempty" json:"local_src_real_cidrs,omitempty"`
+	LocalSourceVirtualCIDRs       string `form:"local_source_virtual_cidrs,omitempty" json:"local_src_virtual_cidrs,omitempty"`
+	LocalDestinationRealCIDRs     string `form:"local_destination_real_cidrs,omitempty" json:"local_dst_real_cidrs,omitempty"`
+	LocalDestinationVirtualCIDRs  string `form:"local_destination_virtual_cidrs,omitempty" json:"local_dst_virtual_cidrs,omitempty"`
+}
+
+// Site2Cloud connection types.
+const (
+	Site2CloudUnmapped = "unmapped"
+	Site2CloudMapped   = "mapped"
+)
+
+// IKEVersion selects the IKE protocol version of a connection.
+type IKEVersion string
+
+const (
+	IKEv1 IKEVersion = "ikev1"
+	IKEv2 IKEVersion = "ikev2"
+)
+
+// Valid reports whether v is a known IKE version.
+func (v IKEVersion) Valid() bool {
+	return v == IKEv1 || v == IKEv2
+}
+
+// EncryptionAlgorithm is a phase 1 or phase 2 cipher, named as the controller
+// names it.
+type EncryptionAlgorithm string
+
+const (
+	AES128CBC    EncryptionAlgorithm = "AES-128-CBC"
+	AES192CBC    EncryptionAlgorithm = "AES-192-CBC"
+	AES256CBC    EncryptionAlgorithm = "AES-256-CBC"
+	AES128GCM64  EncryptionAlgorithm = "AES-128-GCM-64"
+	AES128GCM96  EncryptionAlgorithm = "AES-128-GCM-96"
+	AES128GCM128 EncryptionAlgorithm = "AES-128-GCM-128"
+	AES256GCM64  EncryptionAlgorithm = "AES-256-GCM-64"
+	AES256GCM96  EncryptionAlgorithm = "AES-256-GCM-96"
+	AES256GCM128 EncryptionAlgorithm = "AES-256-GCM-128"
+	TripleDES    EncryptionAlgorithm = "3DES"
+	NullEncr     EncryptionAlgorithm = "NULL-ENCR"
+)
+
+// Valid reports whether a is a known cipher. GCM ciphers and NULL-ENCR are
+// only valid in phase 2.
+func (a EncryptionAlgorithm) Valid() bool {
+	switch a {
+	case AES128CBC, AES192CBC, AES256CBC, AES128GCM64, AES128GCM96, AES128GCM128,
+		AES256GCM64, AES256GCM96, AES256GCM128, TripleDES, NullEncr:
+		return true
+	}
+	return false
+}
+
+// IsAEAD reports whether a provides integrity itself, in which case phase 2
+// takes no separate authentication algorithm.
+func (a EncryptionAlgorithm) IsAEAD() bool {
+	return strings.Contains(string(a), "-GCM-")
+}
+
+// HashAlgorithm is a phase 1 or phase 2 integrity algorithm. Phase 1 uses the
+// SHA values and phase 2 the HMAC values.
+type HashAlgorithm string
+
+const (
+	SHA1       HashAlgorithm = "SHA-1"
+	SHA256     HashAlgorithm = "SHA-256"
+	SHA384     HashAlgorithm = "SHA-384"
+	SHA512     HashAlgorithm = "SHA-512"
+	HMACSHA1   HashAlgorithm = "HMAC-SHA-1"
+	HMACSHA256 HashAlgorithm = "HMAC-SHA-256"
+	HMACSHA384 HashAlgorithm = "HMAC-SHA-384"
+	HMACSHA512 HashAlgorithm = "HMAC-SHA-512"
+	NoAuth     HashAlgorithm = "NO-AUTH"
+)
+
+// Valid reports whether a is a known integrity algorithm.
+func (a HashAlgorithm) Valid() bool {
+	switch a {
+	case SHA1, SHA256, SHA384, SHA512, HMACSHA1, HMACSHA256, HMACSHA384, HMACSHA512, NoAuth:
+		return true
+	}
+	return false
+}
+
+// DHGroup is a Diffie-Hellman group number, used for the IKE exchange in
+// phase 1 and for perfect forward secrecy in phase 2.
+type DHGroup string
+
+const (
+	DHGroup1  DHGroup = "1"
+	DHGroup2  DHGroup = "2"
+	DHGroup5  DHGroup = "5"
+	DHGroup14 DHGroup = "14"
+	DHGroup15 DHGroup = "15"
+	DHGroup16 DHGroup = "16"
+	DHGroup17 DHGroup = "17"
+	DHGroup18 DHGroup = "18"
+	DHGroup19 DHGroup = "19"
+	DHGroup20 DHGroup = "20"
+	DHGroup21 DHGroup = "21"
+)
+
+// Valid reports whether g is a supported group.
+func (g DHGroup) Valid() bool {
+	switch g {
+	case DHGroup1, DHGroup2, DHGroup5, DHGroup14, DHGroup15, DHGroup16, DHGroup17, DHGroup18,
+		DHGroup19, DHGroup20, DHGroup21:
+		return true
+	}
+	return false
 }
 
 type Site2CloudResp struct {
@@ -39,7 +175,20 @@ type Site2CloudConnList struct {
 	Connections []Site2Cloud `json:"connections"`
 }
 
+type Site2CloudDetailResp struct {
+	Return  bool                 `json:"return"`
+	Results Site2CloudConnDetail `json:"results"`
+	Reason  string               `json:"reason"`
+}
+
+type Site2CloudConnDetail struct {
+	Connections Site2Cloud `json:"connections"`
+}
+
 func (c *Client) CreateSite2Cloud(site2cloud *Site2Cloud) error {
+	if err := site2cloud.Validate(); err != nil {
+		return err
+	}
 	site2cloud.CID = c.CID
 	site2cloud.Action = "add_site2cloud"
 	resp, err := c.Post(c.baseURL, site2cloud)
@@ -57,10 +206,50 @@ func (c *Client) CreateSite2Cloud(site2cloud *Site2Cloud) error {
 	return nil
 }
 
-func (c *Client) GetSite2Cloud(site2cloud *Site2Cloud) (*Site2Cloud, error) {
-	site2cloud.Action = "list_site2cloud_conn"
-	path := c.baseURL + fmt.Sprintf("?CID=%s&action=%s&connection_name=%s", c.CID, site2cloud.Action,
-		site2cloud.TunnelName)
+// Site2CloudFilter selects connections in ListSite2Cloud. Empty fields match
+// every connection; GwName matches the primary or the backup gateway, and
+// TunnelType and HAEnabled are compared case-insensitively.
+type Site2CloudFilter struct {
+	TunnelName string
+	VpcID      string
+	GwName     string
+	RemoteGwIP string
+	TunnelType string
+	HAEnabled  string
+}
+
+func (f *Site2CloudFilter) matches(conn *Site2Cloud) bool {
+	return (f.TunnelName == "" || f.TunnelName == conn.TunnelName) &&
+		(f.VpcID == "" || f.VpcID == conn.VpcID) &&
+		(f.GwName == "" || f.GwName == conn.GwName || f.GwName == conn.BackupGwName) &&
+		(f.RemoteGwIP == "" || f.RemoteGwIP == conn.RemoteGwIP || f.RemoteGwIP == conn.RemoteGwIP2) &&
+		(f.TunnelType == "" || strings.EqualFold(f.TunnelType, conn.TunnelType)) &&
+		(f.HAEnabled == "" || strings.EqualFold(f.HAEnabled, conn.HAEnabled))
+}
+
+// Site2CloudAmbiguousError is returned when a connection is looked up by name
+// alone and connections with that name exist in several VPCs.
+type Site2CloudAmbiguousError struct {
+	TunnelName string
+	VpcIDs     []string
+}
+
+func (e *Site2CloudAmbiguousError) Error() string {
+	return fmt.Sprintf("site2cloud connection %s exists in several VPCs: %s", e.TunnelName,
+		strings.Join(e.VpcIDs, ", "))
+}
+
+// ListSite2Cloud returns the Site2Cloud connections matching filter, or all
+// connections if filter is nil. Only the fields reported by
+// list_site2cloud_conn are set; use GetSite2Cloud for the IPsec parameters.
+func (c *Client) ListSite2Cloud(filter *Site2CloudFilter) ([]Site2Cloud, error) {
+	if filter == nil {
+		filter = &Site2CloudFilter{}
+	}
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_site2cloud_conn", c.CID)
+	if filter.TunnelName != "" {
+		path += fmt.Sprintf("&connection_name=%s", filter.TunnelName)
+	}
 	resp, err := c.Get(path, nil)
 	if err != nil {
 		return nil, err
@@ -72,23 +261,132 @@ func (c *Client) GetSite2Cloud(site2cloud *Site2Cloud) (*Site2Cloud, error) {
 	if !data.Return {
 		return nil, errors.New(data.Reason)
 	}
-	for i := 0; i < len(data.Results.Connections); i++ {
-		conn := data.Results.Connections[i]
-		if site2cloud.VpcID == conn.VpcID {
-			return &conn, nil
+	conns := make([]Site2Cloud, 0)
+	for i := range data.Results.Connections {
+		if filter.matches(&data.Results.Connections[i]) {
+			conns = append(conns, data.Results.Connections[i])
+		}
+	}
+	return conns, nil
+}
+
+// GetSite2Cloud returns the connection named site2cloud.TunnelName in
+// site2cloud.VpcID, including its IPsec parameters unless the controller is
+// too old to report them.
+// With an empty VpcID the connection is looked up by name alone, as in
+// FindSite2CloudByName.
+func (c *Client) GetSite2Cloud(site2cloud *Site2Cloud) (*Site2Cloud, error) {
+	if site2cloud.VpcID == "" {
+		return c.FindSite2CloudByName(site2cloud.TunnelName)
+	}
+	conns, err := c.ListSite2Cloud(&Site2CloudFilter{TunnelName: site2cloud.TunnelName, VpcID: site2cloud.VpcID})
+	if err != nil {
+		return nil, err
+	}
+	if len(conns) == 0 {
+		return nil, ErrNotFound
+	}
+	conn := conns[0]
+	if err := c.getSite2CloudDetailIfSupported(&conn); err != nil {
+		return nil, err
+	}
+	return &conn, nil
+}
+
+// FindSite2CloudByName returns the connection named name in whatever VPC it
+// is in, including its IPsec parameters unless the controller is too old to
+// report them. If connections with that name exist in several VPCs a
+// *Site2CloudAmbiguousError is returned instead of picking one.
+func (c *Client) FindSite2CloudByName(name string) (*Site2Cloud, error) {
+	conns, err := c.ListSite2Cloud(&Site2CloudFilter{TunnelName: name})
+	if err != nil {
+		return nil, err
+	}
+	switch len(conns) {
+	case 0:
+		return nil, ErrNotFound
+	case 1:
+	default:
+		vpcIDs := make([]string, 0, len(conns))
+		for _, conn := range conns {
+			vpcIDs = append(vpcIDs, conn.VpcID)
 		}
+		sort.Strings(vpcIDs)
+		return nil, &Site2CloudAmbiguousError{TunnelName: name, VpcIDs: vpcIDs}
+	}
+	conn := conns[0]
+	if err := c.getSite2CloudDetailIfSupported(&conn); err != nil {
+		return nil, err
 	}
-	return nil, ErrNotFound
+	return &conn, nil
+}
 
+// getSite2CloudDetailIfSupported calls getSite2CloudDetail. A controller
+// without get_site2cloud_conn_detail, which rejects it as an unknown action,
+// is only logged and leaves the IPsec parameters empty; every other failure
+// is returned.
+func (c *Client) getSite2CloudDetailIfSupported(site2cloud *Site2Cloud) error {
+	err := c.getSite2CloudDetail(site2cloud)
+	if err != nil && strings.Contains(strings.ToLower(err.Error()), "unknown action") {
+		log.Printf("[WARN] Couldn't get IPsec parameters of s2c connection %s: %v", site2cloud.TunnelName, err)
+		return nil
+	}
+	return err
+}
+
+// getSite2CloudDetail fills in the IPsec parameters and subnet mappings of a
+// connection, which list_site2cloud_conn does not report.
+func (c *Client) getSite2CloudDetail(site2cloud *Site2Cloud) error {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=get_site2cloud_conn_detail&vpc_id=%s&conn_name=%s", c.CID,
+		site2cloud.VpcID, site2cloud.TunnelName)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return err
+	}
+	var data Site2CloudDetailResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+	if !data.Return {
+		return errors.New(data.Reason)
+	}
+	detail := data.Results.Connections
+	site2cloud.IKEVersion = detail.IKEVersion
+	site2cloud.Phase1Encryption = detail.Phase1Encryption
+	site2cloud.Phase1Auth = detail.Phase1Auth
+	site2cloud.Phase1DHGroup = detail.Phase1DHGroup
+	site2cloud.Phase2Encryption = detail.Phase2Encryption
+	site2cloud.Phase2Auth = detail.Phase2Auth
+	site2cloud.Phase2DHGroup = detail.Phase2DHGroup
+	site2cloud.LocalSubnetVirtual = detail.LocalSubnetVirtual
+	site2cloud.RemoteSubnetVirtual = detail.RemoteSubnetVirtual
+	site2cloud.CustomMapped = detail.CustomMapped
+	site2cloud.RemoteSourceRealCIDRs = detail.RemoteSourceRealCIDRs
+	site2cloud.RemoteSourceVirtualCIDRs = detail.RemoteSourceVirtualCIDRs
+	site2cloud.RemoteDestinationRealCIDRs = detail.RemoteDestinationRealCIDRs
+	site2cloud.RemoteDestinationVirtualCIDRs = detail.RemoteDestinationVirtualCIDRs
+	site2cloud.LocalSourceRealCIDRs = detail.LocalSourceRealCIDRs
+	site2cloud.LocalSourceVirtualCIDRs = detail.LocalSourceVirtualCIDRs
+	site2cloud.LocalDestinationRealCIDRs = detail.LocalDestinationRealCIDRs
+	site2cloud.LocalDestinationVirtualCIDRs = detail.LocalDestinationVirtualCIDRs
+	return nil
 }
 
 func (c *Client) UpdateSite2Cloud(site2cloud *Site2Cloud) error {
 	site2cloud.CID = c.CID
 	site2cloud.Action = "edit_site2cloud_conn"
 	verb := "POST"
+	if err := site2cloud.Validate(); err != nil {
+		return err
+	}
 	body := fmt.Sprintf("CID=%s&action=%s&vpc_id=%s&conn_name=%s&local_subnet_cidr=%s&remote_subnet_cidr=%s",
 		c.CID, site2cloud.Action, site2cloud.VpcID, site2cloud.TunnelName, site2cloud.LocalSubnet,
 		site2cloud.RemoteSubnet)
+	for _, param := range site2cloud.optionalParams() {
+		if param[1] != "" {
+			body += fmt.Sprintf("&%s=%s", param[0], url.QueryEscape(param[1]))
+		}
+	}
 	log.Printf("[TRACE] %s %s Body: %s", verb, c.baseURL, body)
 	req, err := http.NewRequest(verb, c.baseURL, strings.NewReader(body))
 	if err == nil {
@@ -111,6 +409,114 @@ func (c *Client) UpdateSite2Cloud(site2cloud *Site2Cloud) error {
 	return nil
 }
 
+// Validate checks the IPsec parameters and subnet mappings of the connection.
+// Empty parameters are not checked, since they keep the controller defaults.
+func (s *Site2Cloud) Validate() error {
+	if s.IKEVersion != "" && !s.IKEVersion.Valid() {
+		return fmt.Errorf("invalid IKE version %q", s.IKEVersion)
+	}
+	for _, enc := range []EncryptionAlgorithm{s.Phase1Encryption, s.Phase2Encryption} {
+		if enc != "" && !enc.Valid() {
+			return fmt.Errorf("invalid encryption algorithm %q", enc)
+		}
+	}
+	if s.Phase1Encryption.IsAEAD() || s.Phase1Encryption == NullEncr {
+		return fmt.Errorf("encryption algorithm %s is not supported in phase 1", s.Phase1Encryption)
+	}
+	for _, auth := range []HashAlgorithm{s.Phase1Auth, s.Phase2Auth} {
+		if auth != "" && !auth.Valid() {
+			return fmt.Errorf("invalid authentication algorithm %q", auth)
+		}
+	}
+	if strings.HasPrefix(string(s.Phase1Auth), "HMAC-") || s.Phase1Auth == NoAuth {
+		return fmt.Errorf("authentication algorithm %s is not supported in phase 1", s.Phase1Auth)
+	}
+	if s.Phase2Auth != "" && !strings.HasPrefix(string(s.Phase2Auth), "HMAC-") && s.Phase2Auth != NoAuth {
+		return fmt.Errorf("authentication algorithm %s is not supported in phase 2", s.Phase2Auth)
+	}
+	if s.Phase2Encryption.IsAEAD() && s.Phase2Auth != "" && s.Phase2Auth != NoAuth {
+		return fmt.Errorf("%s provides its own integrity, phase 2 authentication must be %s", s.Phase2Encryption,
+			NoAuth)
+	}
+	for _, group := range []DHGroup{s.Phase1DHGroup, s.Phase2DHGroup} {
+		if group != "" && !group.Valid() {
+			return fmt.Errorf("invalid DH group %q", group)
+		}
+	}
+
+	if s.ConnType != "" && s.ConnType != Site2CloudUnmapped && s.ConnType != Site2CloudMapped {
+		return fmt.Errorf("connection type must be %s or %s, got %q", Site2CloudUnmapped, Site2CloudMapped,
+			s.ConnType)
+	}
+	// an empty ConnType leaves the type to the controller
+	mapped := s.LocalSubnetVirtual != "" || s.RemoteSubnetVirtual != "" || s.CustomMapped == "true"
+	if mapped && s.ConnType == Site2CloudUnmapped {
+		return errors.New("subnet mappings require a mapped connection")
+	}
+	if s.CustomMapped == "true" {
+		pairs := [][2]string{
+			{s.RemoteSourceRealCIDRs, s.RemoteSourceVirtualCIDRs},
+			{s.RemoteDestinationRealCIDRs, s.RemoteDestinationVirtualCIDRs},
+			{s.LocalSourceRealCIDRs, s.LocalSourceVirtualCIDRs},
+			{s.LocalDestinationRealCIDRs, s.LocalDestinationVirtualCIDRs},
+		}
+		for _, pair := range pairs {
+			realCIDRs, virtualCIDRs := splitCIDRList(pair[0]), splitCIDRList(pair[1])
+			if len(realCIDRs) != len(virtualCIDRs) {
+				return fmt.Errorf("mapped CIDR lists %q and %q differ in length", pair[0], pair[1])
+			}
+			for _, cidr := range append(realCIDRs, virtualCIDRs...) {
+				if _, err := canonicalCIDR(cidr); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// optionalParams lists the form fields sent on update besides the subnets.
+func (s *Site2Cloud) optionalParams() [][2]string {
+	return [][2]string{
+		{"ike_version", string(s.IKEVersion)},
+		{"phase1_encryption", string(s.Phase1Encryption)},
+		{"phase1_auth", string(s.Phase1Auth)},
+		{"phase1_dh_group", string(s.Phase1DHGroup)},
+		{"phase2_encryption", string(s.Phase2Encryption)},
+		{"phase2_auth", string(s.Phase2Auth)},
+		{"phase2_dh_group", string(s.Phase2DHGroup)},
+		{"virtual_local_subnet_cidr", s.LocalSubnetVirtual},
+		{"virtual_remote_subnet_cidr", s.RemoteSubnetVirtual},
+		{"custom_mapped", s.CustomMapped},
+		{"remote_source_real_cidrs", s.RemoteSourceRealCIDRs},
+		{"remote_source_virtual_cidrs", s.RemoteSourceVirtualCIDRs},
+		{"remote_destination_real_cidrs", s.RemoteDestinationRealCIDRs},
+		{"remote_destination_virtual_cidrs", s.RemoteDestinationVirtualCIDRs},
+		{"local_source_real_cidrs", s.LocalSourceRealCIDRs},
+		{"local_source_virtual_cidrs", s.LocalSourceVirtualCIDRs},
+		{"local_destination_real_cidrs", s.LocalDestinationRealCIDRs},
+		{"local_destination_virtual_cidrs", s.LocalDestinationVirtualCIDRs},
+	}
+}
+
+func site2CloudHAEnabled(site2cloud *Site2Cloud) bool {
+	switch strings.ToLower(site2cloud.HAEnabled) {
+	case "enabled", "yes", "true":
+		return true
+	}
+	return false
+}
+
+func splitCIDRList(list string) []string {
+	cidrs := make([]string, 0)
+	for _, cidr := range strings.Split(list, ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	return cidrs
+}
+
 func (c *Client) DeleteSite2Cloud(site2cloud *Site2Cloud) error {
 	site2cloud.CID = c.CID
 	site2cloud.Action = "delete_site2cloud_connection"
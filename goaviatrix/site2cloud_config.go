@@ -0,0 +1,703 @@
+package goaviatrix
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+	"net"
+	"net/url"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// RemoteConfigFormat selects the device configuration rendered by
+// RenderSite2CloudConfig.
+type RemoteConfigFormat string
+
+const (
+	StrongSwanIPsecConf RemoteConfigFormat = "strongswan-ipsec.conf"
+	StrongSwanSwanctl   RemoteConfigFormat = "strongswan-swanctl.conf"
+	CiscoIOS            RemoteConfigFormat = "cisco-ios"
+	PfSenseXML          RemoteConfigFormat = "pfsense-xml"
+)
+
+// RemoteConfigParams holds what RenderSite2CloudConfig needs beyond the
+// Site2Cloud connection: the public IPs of the cloud gateways the remote
+// device connects to. BackupCloudGwIP is only used for HA connections.
+type RemoteConfigParams struct {
+	CloudGwIP       string
+	BackupCloudGwIP string
+}
+
+// Defaults used by the controller for IPsec parameters left empty.
+const (
+	defaultSite2CloudEncryption = AES256CBC
+	defaultSite2CloudPhase1Auth = SHA256
+	defaultSite2CloudPhase2Auth = HMACSHA256
+	defaultSite2CloudDHGroup    = DHGroup14
+	defaultSite2CloudIKEVersion = IKEv1
+)
+
+type Site2CloudConfigResp struct {
+	Return  bool   `json:"return"`
+	Results string `json:"results"`
+	Reason  string `json:"reason"`
+}
+
+// DownloadSite2CloudConfig returns the controller's configuration template for
+// the remote end of a connection. vendor, platform and software name the
+// remote device as listed in the controller's "Download Configuration"
+// dialog, e.g. "Cisco", "ISR, ASR or CSR" and "IOS(XE)".
+func (c *Client) DownloadSite2CloudConfig(site2cloud *Site2Cloud, vendor, platform, software string) (string, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=download_site2cloud_config&vpc_id=%s&connection_name=%s"+
+		"&vendor=%s&platform=%s&software=%s", c.CID, site2cloud.VpcID, site2cloud.TunnelName,
+		url.QueryEscape(vendor), url.QueryEscape(platform), url.QueryEscape(software))
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return "", err
+	}
+	var data Site2CloudConfigResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", err
+	}
+	if !data.Return {
+		return "", errors.New(data.Reason)
+	}
+	return data.Results, nil
+}
+
+// RenderSite2CloudConfig writes the configuration of the remote end of
+// site2cloud for the given device format, offline. The remote device protects
+// site2cloud.RemoteSubnet and reaches site2cloud.LocalSubnet, or
+// LocalSubnetVirtual for mapped connections. IPsec parameters left empty get
+// the controller's defaults. Custom mapped connections are not supported.
+func RenderSite2CloudConfig(w io.Writer, format RemoteConfigFormat, site2cloud *Site2Cloud,
+	params RemoteConfigParams) error {
+	view, err := newSite2CloudView(site2cloud, params)
+	if err != nil {
+		return err
+	}
+	switch format {
+	case StrongSwanIPsecConf, StrongSwanSwanctl:
+		view.strongSwanNames()
+		return site2CloudTemplates.ExecuteTemplate(w, string(format), view)
+	case CiscoIOS:
+		if err := view.ciscoNames(); err != nil {
+			return err
+		}
+		return site2CloudTemplates.ExecuteTemplate(w, string(format), view)
+	case PfSenseXML:
+		return renderPfSense(w, view)
+	}
+	return fmt.Errorf("unknown remote config format %q", format)
+}
+
+// site2CloudView is the connection as seen from the remote device, with the
+// IPsec parameters filled in and translated for the target format.
+type site2CloudView struct {
+	Name          string
+	IKEVersion    IKEVersion
+	Tunnels       []site2CloudTunnel
+	LocalSubnets  []site2CloudSubnet
+	RemoteSubnets []site2CloudSubnet
+
+	Phase1Encryption EncryptionAlgorithm
+	Phase1Auth       HashAlgorithm
+	Phase1DHGroup    DHGroup
+	Phase2Encryption EncryptionAlgorithm
+	Phase2Auth       HashAlgorithm
+	Phase2DHGroup    DHGroup
+
+	// Proposal strings set by strongSwanNames or ciscoNames.
+	IKEProposal     string
+	ESPProposal     string
+	Phase1Cipher    string
+	Phase1Integrity string
+	TransformSet    string
+}
+
+type site2CloudTunnel struct {
+	Name         string
+	RemoteGwIP   string
+	CloudGwIP    string
+	PreSharedKey string
+}
+
+type site2CloudSubnet struct {
+	CIDR     string
+	Network  string
+	Wildcard string
+	Bits     int
+}
+
+func newSite2CloudView(s *Site2Cloud, params RemoteConfigParams) (*site2CloudView, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	if s.CustomMapped == "true" {
+		return nil, errors.New("custom mapped connections are not supported")
+	}
+	if params.CloudGwIP == "" {
+		return nil, errors.New("cloud gateway IP is required")
+	}
+	view := &site2CloudView{
+		Name:             s.TunnelName,
+		IKEVersion:       s.IKEVersion,
+		Phase1Encryption: s.Phase1Encryption,
+		Phase1Auth:       s.Phase1Auth,
+		Phase1DHGroup:    s.Phase1DHGroup,
+		Phase2Encryption: s.Phase2Encryption,
+		Phase2Auth:       s.Phase2Auth,
+		Phase2DHGroup:    s.Phase2DHGroup,
+	}
+	if view.Name == "" {
+		return nil, errors.New("connection name is required")
+	}
+	// the name is an identifier or section name in every format
+	if strings.ContainsAny(view.Name, " \t{}#!") || !site2CloudConfigSafe(view.Name) {
+		return nil, fmt.Errorf("connection name %q cannot be written to a device configuration", view.Name)
+	}
+	for _, key := range []string{s.PreSharedKey, s.BackupPreSharedKey} {
+		if !site2CloudConfigSafe(key) {
+			return nil, errors.New("pre-shared key cannot contain quotes, backslashes or control characters")
+		}
+	}
+	if view.IKEVersion == "" {
+		view.IKEVersion = defaultSite2CloudIKEVersion
+	}
+	if view.Phase1Encryption == "" {
+		view.Phase1Encryption = defaultSite2CloudEncryption
+	}
+	if view.Phase1Auth == "" {
+		view.Phase1Auth = defaultSite2CloudPhase1Auth
+	}
+	if view.Phase1DHGroup == "" {
+		view.Phase1DHGroup = defaultSite2CloudDHGroup
+	}
+	if view.Phase2Encryption == "" {
+		view.Phase2Encryption = defaultSite2CloudEncryption
+	}
+	if view.Phase2Auth == "" {
+		view.Phase2Auth = defaultSite2CloudPhase2Auth
+		if view.Phase2Encryption.IsAEAD() {
+			view.Phase2Auth = NoAuth
+		}
+	}
+	if view.Phase2DHGroup == "" {
+		view.Phase2DHGroup = defaultSite2CloudDHGroup
+	}
+
+	cloudSubnet := s.LocalSubnet
+	if s.ConnType == Site2CloudMapped && s.LocalSubnetVirtual != "" {
+		cloudSubnet = s.LocalSubnetVirtual
+	}
+	var err error
+	if view.LocalSubnets, err = newSite2CloudSubnets(s.RemoteSubnet); err != nil {
+		return nil, err
+	}
+	if view.RemoteSubnets, err = newSite2CloudSubnets(cloudSubnet); err != nil {
+		return nil, err
+	}
+	if len(view.LocalSubnets) == 0 || len(view.RemoteSubnets) == 0 {
+		return nil, errors.New("local and remote subnets are required")
+	}
+
+	if s.RemoteGwIP == "" {
+		return nil, errors.New("remote gateway IP is required")
+	}
+	view.Tunnels = append(view.Tunnels, site2CloudTunnel{
+		Name:         s.TunnelName,
+		RemoteGwIP:   s.RemoteGwIP,
+		CloudGwIP:    params.CloudGwIP,
+		PreSharedKey: s.PreSharedKey,
+	})
+	if site2CloudHAEnabled(s) {
+		if params.BackupCloudGwIP == "" {
+			return nil, errors.New("backup cloud gateway IP is required for HA connections")
+		}
+		backup := site2CloudTunnel{
+			Name:         s.TunnelName + "-backup",
+			RemoteGwIP:   firstNonEmpty(s.RemoteGwIP2, s.RemoteGwIP),
+			CloudGwIP:    params.BackupCloudGwIP,
+			PreSharedKey: firstNonEmpty(s.BackupPreSharedKey, s.PreSharedKey),
+		}
+		view.Tunnels = append(view.Tunnels, backup)
+	}
+	return view, nil
+}
+
+// site2CloudConfigSafe reports whether value can be written into the
+// rendered configurations as is. The strongSwan formats quote the pre-shared
+// key and the others write it bare, so quotes, backslashes and line breaks
+// cannot be escaped in all of them and are rejected instead.
+func site2CloudConfigSafe(value string) bool {
+	for _, r := range value {
+		if r == '"' || r == '\\' || r < 0x20 || r == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+func newSite2CloudSubnets(list string) ([]site2CloudSubnet, error) {
+	subnets := make([]site2CloudSubnet, 0)
+	for _, cidr := range splitCIDRList(list) {
+		canonical, err := canonicalCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		r, _, _ := parseIPv4Range(canonical)
+		subnets = append(subnets, site2CloudSubnet{
+			CIDR:     canonical,
+			Network:  uint32ToIP(r.lo),
+			Wildcard: uint32ToIP(r.hi - r.lo),
+			Bits:     32 - bits.Len32(r.hi-r.lo),
+		})
+	}
+	return subnets, nil
+}
+
+func uint32ToIP(v uint32) string {
+	ip := make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ip, v)
+	return ip.String()
+}
+
+var strongSwanCiphers = map[EncryptionAlgorithm]string{
+	AES128CBC:    "aes128",
+	AES192CBC:    "aes192",
+	AES256CBC:    "aes256",
+	AES128GCM64:  "aes128gcm8",
+	AES128GCM96:  "aes128gcm12",
+	AES128GCM128: "aes128gcm16",
+	AES256GCM64:  "aes256gcm8",
+	AES256GCM96:  "aes256gcm12",
+	AES256GCM128: "aes256gcm16",
+	TripleDES:    "3des",
+	NullEncr:     "null",
+}
+
+var strongSwanHashes = map[HashAlgorithm]string{
+	SHA1:       "sha1",
+	SHA256:     "sha256",
+	SHA384:     "sha384",
+	SHA512:     "sha512",
+	HMACSHA1:   "sha1",
+	HMACSHA256: "sha256",
+	HMACSHA384: "sha384",
+	HMACSHA512: "sha512",
+	NoAuth:     "",
+}
+
+var strongSwanDHGroups = map[DHGroup]string{
+	DHGroup1:  "modp768",
+	DHGroup2:  "modp1024",
+	DHGroup5:  "modp1536",
+	DHGroup14: "modp2048",
+	DHGroup15: "modp3072",
+	DHGroup16: "modp4096",
+	DHGroup17: "modp6144",
+	DHGroup18: "modp8192",
+	DHGroup19: "ecp256",
+	DHGroup20: "ecp384",
+	DHGroup21: "ecp521",
+}
+
+func (v *site2CloudView) strongSwanNames() {
+	join := func(parts ...string) string {
+		nonEmpty := make([]string, 0, len(parts))
+		for _, part := range parts {
+			if part != "" {
+				nonEmpty = append(nonEmpty, part)
+			}
+		}
+		return strings.Join(nonEmpty, "-")
+	}
+	v.IKEProposal = join(strongSwanCiphers[v.Phase1Encryption], strongSwanHashes[v.Phase1Auth],
+		strongSwanDHGroups[v.Phase1DHGroup])
+	v.ESPProposal = join(strongSwanCiphers[v.Phase2Encryption], strongSwanHashes[v.Phase2Auth],
+		strongSwanDHGroups[v.Phase2DHGroup])
+}
+
+var ciscoISAKMPCiphers = map[EncryptionAlgorithm]string{
+	AES128CBC: "aes 128",
+	AES192CBC: "aes 192",
+	AES256CBC: "aes 256",
+	TripleDES: "3des",
+}
+
+var ciscoIKEv2Ciphers = map[EncryptionAlgorithm]string{
+	AES128CBC: "aes-cbc-128",
+	AES192CBC: "aes-cbc-192",
+	AES256CBC: "aes-cbc-256",
+	TripleDES: "3des",
+}
+
+var ciscoISAKMPHashes = map[HashAlgorithm]string{
+	SHA1:   "sha",
+	SHA256: "sha256",
+	SHA384: "sha384",
+	SHA512: "sha512",
+}
+
+var ciscoIKEv2Hashes = map[HashAlgorithm]string{
+	SHA1:   "sha1",
+	SHA256: "sha256",
+	SHA384: "sha384",
+	SHA512: "sha512",
+}
+
+var ciscoESPCiphers = map[EncryptionAlgorithm]string{
+	AES128CBC:    "esp-aes 128",
+	AES192CBC:    "esp-aes 192",
+	AES256CBC:    "esp-aes 256",
+	AES128GCM128: "esp-gcm 128",
+	AES256GCM128: "esp-gcm 256",
+	TripleDES:    "esp-3des",
+	NullEncr:     "esp-null",
+}
+
+var ciscoESPHashes = map[HashAlgorithm]string{
+	HMACSHA1:   "esp-sha-hmac",
+	HMACSHA256: "esp-sha256-hmac",
+	HMACSHA384: "esp-sha384-hmac",
+	HMACSHA512: "esp-sha512-hmac",
+	NoAuth:     "",
+}
+
+func (v *site2CloudView) ciscoNames() error {
+	// IOS takes the key as an unquoted word, so it cannot contain whitespace.
+	for _, tunnel := range v.Tunnels {
+		if strings.IndexFunc(tunnel.PreSharedKey, unicode.IsSpace) >= 0 {
+			return fmt.Errorf("pre-shared key of tunnel %s cannot contain whitespace in a Cisco IOS configuration",
+				tunnel.Name)
+		}
+	}
+	ciphers, hashes := ciscoISAKMPCiphers, ciscoISAKMPHashes
+	if v.IKEVersion == IKEv2 {
+		ciphers, hashes = ciscoIKEv2Ciphers, ciscoIKEv2Hashes
+	}
+	var ok bool
+	if v.Phase1Cipher, ok = ciphers[v.Phase1Encryption]; !ok {
+		return fmt.Errorf("phase 1 encryption %s is not supported by Cisco IOS", v.Phase1Encryption)
+	}
+	if v.Phase1Integrity, ok = hashes[v.Phase1Auth]; !ok {
+		return fmt.Errorf("phase 1 authentication %s is not supported by Cisco IOS", v.Phase1Auth)
+	}
+	cipher, ok := ciscoESPCiphers[v.Phase2Encryption]
+	if !ok {
+		return fmt.Errorf("phase 2 encryption %s is not supported by Cisco IOS", v.Phase2Encryption)
+	}
+	hash, ok := ciscoESPHashes[v.Phase2Auth]
+	if !ok {
+		return fmt.Errorf("phase 2 authentication %s is not supported by Cisco IOS", v.Phase2Auth)
+	}
+	v.TransformSet = strings.TrimSpace(cipher + " " + hash)
+	return nil
+}
+
+var site2CloudTemplates = template.Must(template.New("site2cloud").Funcs(template.FuncMap{
+	"cidrs": func(subnets []site2CloudSubnet) string {
+		cidrs := make([]string, len(subnets))
+		for i, subnet := range subnets {
+			cidrs[i] = subnet.CIDR
+		}
+		return strings.Join(cidrs, ",")
+	},
+	"ikeNumber": func(v IKEVersion) string { return strings.TrimPrefix(string(v), "ikev") },
+}).Parse(`
+{{- define "strongswan-ipsec.conf" -}}
+# ipsec.conf for Aviatrix Site2Cloud connection {{.Name}}
+
+conn %default
+	keyexchange={{.IKEVersion}}
+	authby=secret
+	ike={{.IKEProposal}}!
+	esp={{.ESPProposal}}!
+	ikelifetime=28800s
+	lifetime=3600s
+	dpddelay=10s
+	dpdtimeout=30s
+	dpdaction=restart
+	auto=start
+{{range .Tunnels}}
+conn {{.Name}}
+	left=%defaultroute
+	leftid={{.RemoteGwIP}}
+	leftsubnet={{cidrs $.LocalSubnets}}
+	right={{.CloudGwIP}}
+	rightid={{.CloudGwIP}}
+	rightsubnet={{cidrs $.RemoteSubnets}}
+{{end}}
+# Add to ipsec.secrets:
+{{- range .Tunnels}}
+# {{.RemoteGwIP}} {{.CloudGwIP}} : PSK "{{.PreSharedKey}}"
+{{- end}}
+{{end -}}
+
+{{- define "strongswan-swanctl.conf" -}}
+# swanctl.conf for Aviatrix Site2Cloud connection {{.Name}}
+
+connections {
+{{- range .Tunnels}}
+	{{.Name}} {
+		version = {{ikeNumber $.IKEVersion}}
+		local_addrs = %any
+		remote_addrs = {{.CloudGwIP}}
+		proposals = {{$.IKEProposal}}
+		rekey_time = 28800s
+		dpd_delay = 10s
+		local {
+			auth = psk
+			id = {{.RemoteGwIP}}
+		}
+		remote {
+			auth = psk
+			id = {{.CloudGwIP}}
+		}
+		children {
+			{{.Name}} {
+				local_ts = {{cidrs $.LocalSubnets}}
+				remote_ts = {{cidrs $.RemoteSubnets}}
+				esp_proposals = {{$.ESPProposal}}
+				rekey_time = 3600s
+				dpd_action = restart
+				start_action = start
+			}
+		}
+	}
+{{- end}}
+}
+
+secrets {
+{{- range $i, $t := .Tunnels}}
+	ike-{{$t.Name}} {
+		id-{{$i}} = {{$t.CloudGwIP}}
+		secret = "{{$t.PreSharedKey}}"
+	}
+{{- end}}
+}
+{{end -}}
+
+{{- define "cisco-ios" -}}
+! Cisco IOS configuration for Aviatrix Site2Cloud connection {{.Name}}
+!
+{{- if eq .IKEVersion "ikev2"}}
+crypto ikev2 proposal {{.Name}}
+ encryption {{.Phase1Cipher}}
+ integrity {{.Phase1Integrity}}
+ group {{.Phase1DHGroup}}
+!
+crypto ikev2 policy {{.Name}}
+ proposal {{.Name}}
+!
+crypto ikev2 keyring {{.Name}}
+{{- range .Tunnels}}
+ peer {{.Name}}
+  address {{.CloudGwIP}}
+  pre-shared-key {{.PreSharedKey}}
+{{- end}}
+!
+crypto ikev2 profile {{.Name}}
+{{- range .Tunnels}}
+ match identity remote address {{.CloudGwIP}} 255.255.255.255
+{{- end}}
+ authentication remote pre-share
+ authentication local pre-share
+ keyring local {{.Name}}
+ lifetime 28800
+ dpd 10 3 periodic
+{{- else}}
+crypto isakmp policy 10
+ encryption {{.Phase1Cipher}}
+ hash {{.Phase1Integrity}}
+ authentication pre-share
+ group {{.Phase1DHGroup}}
+ lifetime 28800
+!
+{{- range .Tunnels}}
+crypto isakmp key {{.PreSharedKey}} address {{.CloudGwIP}}
+{{- end}}
+crypto isakmp keepalive 10 3 periodic
+{{- end}}
+!
+crypto ipsec transform-set {{.Name}} {{.TransformSet}}
+ mode tunnel
+!
+ip access-list extended {{.Name}}
+{{- range $local := .LocalSubnets}}{{range $remote := $.RemoteSubnets}}
+ permit ip {{$local.Network}} {{$local.Wildcard}} {{$remote.Network}} {{$remote.Wildcard}}
+{{- end}}{{end}}
+!
+crypto map {{.Name}} 10 ipsec-isakmp
+{{- range .Tunnels}}
+ set peer {{.CloudGwIP}}
+{{- end}}
+ set transform-set {{.Name}}
+ set pfs group{{.Phase2DHGroup}}
+ set security-association lifetime seconds 3600
+{{- if eq .IKEVersion "ikev2"}}
+ set ikev2-profile {{.Name}}
+{{- end}}
+ match address {{.Name}}
+!
+! Apply the crypto map to the outside interface:
+! interface <outside-interface>
+!  crypto map {{.Name}}
+{{end -}}
+`))
+
+// pfSense config.xml fragments. Only the elements needed for a working tunnel
+// are written; pfSense fills in the rest when the section is imported.
+type pfSenseIPsec struct {
+	XMLName xml.Name        `xml:"ipsec"`
+	Phase1  []pfSensePhase1 `xml:"phase1"`
+	Phase2  []pfSensePhase2 `xml:"phase2"`
+}
+
+type pfSensePhase1 struct {
+	IKEId         int             `xml:"ikeid"`
+	IKEType       string          `xml:"iketype"`
+	Interface     string          `xml:"interface"`
+	RemoteGateway string          `xml:"remote-gateway"`
+	Protocol      string          `xml:"protocol"`
+	MyIDType      string          `xml:"myid_type"`
+	PeerIDType    string          `xml:"peerid_type"`
+	Encryption    []pfSenseP1Item `xml:"encryption>item"`
+	Lifetime      int             `xml:"lifetime"`
+	AuthMethod    string          `xml:"authentication_method"`
+	PreSharedKey  string          `xml:"pre-shared-key"`
+	Description   string          `xml:"descr"`
+	DPDDelay      int             `xml:"dpd_delay"`
+	DPDMaxFail    int             `xml:"dpd_maxfail"`
+}
+
+type pfSenseP1Item struct {
+	Algorithm pfSenseAlgorithm `xml:"encryption-algorithm"`
+	Hash      string           `xml:"hash-algorithm"`
+	DHGroup   string           `xml:"dhgroup"`
+}
+
+type pfSenseAlgorithm struct {
+	Name   string `xml:"name"`
+	KeyLen string `xml:"keylen"`
+}
+
+type pfSensePhase2 struct {
+	IKEId       int                `xml:"ikeid"`
+	UniqID      string             `xml:"uniqid"`
+	Mode        string             `xml:"mode"`
+	LocalID     pfSenseID          `xml:"localid"`
+	RemoteID    pfSenseID          `xml:"remoteid"`
+	Protocol    string             `xml:"protocol"`
+	Encryption  []pfSenseAlgorithm `xml:"encryption-algorithm-option"`
+	Hash        []string           `xml:"hash-algorithm-option"`
+	PFSGroup    string             `xml:"pfsgroup"`
+	Lifetime    int                `xml:"lifetime"`
+	Description string             `xml:"descr"`
+}
+
+type pfSenseID struct {
+	Type    string `xml:"type"`
+	Address string `xml:"address"`
+	Netbits int    `xml:"netbits"`
+}
+
+var pfSenseCiphers = map[EncryptionAlgorithm]pfSenseAlgorithm{
+	AES128CBC:    {"aes", "128"},
+	AES192CBC:    {"aes", "192"},
+	AES256CBC:    {"aes", "256"},
+	AES128GCM64:  {"aes128gcm", "64"},
+	AES128GCM96:  {"aes128gcm", "96"},
+	AES128GCM128: {"aes128gcm", "128"},
+	AES256GCM64:  {"aes256gcm", "64"},
+	AES256GCM96:  {"aes256gcm", "96"},
+	AES256GCM128: {"aes256gcm", "128"},
+	TripleDES:    {"3des", ""},
+}
+
+var pfSenseHashes = map[HashAlgorithm]string{
+	SHA1:       "sha1",
+	SHA256:     "sha256",
+	SHA384:     "sha384",
+	SHA512:     "sha512",
+	HMACSHA1:   "hmac_sha1",
+	HMACSHA256: "hmac_sha256",
+	HMACSHA384: "hmac_sha384",
+	HMACSHA512: "hmac_sha512",
+}
+
+func renderPfSense(w io.Writer, v *site2CloudView) error {
+	p1Cipher, ok := pfSenseCiphers[v.Phase1Encryption]
+	if !ok {
+		return fmt.Errorf("phase 1 encryption %s is not supported by pfSense", v.Phase1Encryption)
+	}
+	p2Cipher, ok := pfSenseCiphers[v.Phase2Encryption]
+	if !ok {
+		return fmt.Errorf("phase 2 encryption %s is not supported by pfSense", v.Phase2Encryption)
+	}
+	p2Hashes := make([]string, 0)
+	if v.Phase2Auth != NoAuth {
+		p2Hashes = append(p2Hashes, pfSenseHashes[v.Phase2Auth])
+	}
+
+	ipsec := pfSenseIPsec{}
+	for i, tunnel := range v.Tunnels {
+		ikeID := i + 1
+		ipsec.Phase1 = append(ipsec.Phase1, pfSensePhase1{
+			IKEId:         ikeID,
+			IKEType:       string(v.IKEVersion),
+			Interface:     "wan",
+			RemoteGateway: tunnel.CloudGwIP,
+			Protocol:      "inet",
+			MyIDType:      "myaddress",
+			PeerIDType:    "peeraddress",
+			Encryption: []pfSenseP1Item{{
+				Algorithm: p1Cipher,
+				Hash:      pfSenseHashes[v.Phase1Auth],
+				DHGroup:   string(v.Phase1DHGroup),
+			}},
+			Lifetime:     28800,
+			AuthMethod:   "pre_shared_key",
+			PreSharedKey: tunnel.PreSharedKey,
+			Description:  tunnel.Name,
+			DPDDelay:     10,
+			DPDMaxFail:   3,
+		})
+		n := 0
+		for _, local := range v.LocalSubnets {
+			for _, remote := range v.RemoteSubnets {
+				n++
+				ipsec.Phase2 = append(ipsec.Phase2, pfSensePhase2{
+					IKEId:       ikeID,
+					UniqID:      fmt.Sprintf("%s-%d", tunnel.Name, n),
+					Mode:        "tunnel",
+					LocalID:     pfSenseID{Type: "network", Address: local.Network, Netbits: local.Bits},
+					RemoteID:    pfSenseID{Type: "network", Address: remote.Network, Netbits: remote.Bits},
+					Protocol:    "esp",
+					Encryption:  []pfSenseAlgorithm{p2Cipher},
+					Hash:        p2Hashes,
+					PFSGroup:    string(v.Phase2DHGroup),
+					Lifetime:    3600,
+					Description: tunnel.Name,
+				})
+			}
+		}
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(ipsec); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
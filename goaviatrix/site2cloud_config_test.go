@@ -0,0 +1,157 @@
+package goaviatrix
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+func TestRenderSite2CloudConfig(t *testing.T) {
+	connections := map[string]*Site2Cloud{
+		"default": {
+			TunnelName:   "onprem-dc1",
+			ConnType:     Site2CloudUnmapped,
+			RemoteGwIP:   "198.51.100.7",
+			PreSharedKey: "s3cret-key",
+			RemoteSubnet: "192.168.10.0/24",
+			LocalSubnet:  "10.10.0.0/16,10.20.0.0/16",
+		},
+		"mapped-ha": {
+			TunnelName:          "onprem-dc2",
+			ConnType:            Site2CloudMapped,
+			HAEnabled:           "enabled",
+			RemoteGwIP:          "203.0.113.20",
+			PreSharedKey:        "primary-key",
+			BackupPreSharedKey:  "backup-key",
+			RemoteSubnet:        "172.31.0.0/20",
+			LocalSubnet:         "10.0.0.0/16",
+			LocalSubnetVirtual:  "100.64.0.0/16",
+			RemoteSubnetVirtual: "100.65.0.0/20",
+			IKEVersion:          IKEv2,
+			Phase1Encryption:    AES256CBC,
+			Phase1Auth:          SHA384,
+			Phase1DHGroup:       DHGroup20,
+			Phase2Encryption:    AES256GCM128,
+			Phase2Auth:          NoAuth,
+			Phase2DHGroup:       DHGroup20,
+		},
+		"psk-chars": {
+			TunnelName:   "onprem-dc3",
+			RemoteGwIP:   "198.51.100.8",
+			PreSharedKey: "p@ss'w0rd#&<key>!$",
+			RemoteSubnet: "192.168.20.0/24",
+			LocalSubnet:  "10.30.0.0/16",
+		},
+	}
+	params := RemoteConfigParams{CloudGwIP: "52.0.0.10", BackupCloudGwIP: "52.0.0.11"}
+	formats := map[RemoteConfigFormat]string{
+		StrongSwanIPsecConf: "ipsec.conf",
+		StrongSwanSwanctl:   "swanctl.conf",
+		CiscoIOS:            "ios",
+		PfSenseXML:          "pfsense.xml",
+	}
+	for name, s2c := range connections {
+		for format, ext := range formats {
+			t.Run(name+"/"+string(format), func(t *testing.T) {
+				var buf bytes.Buffer
+				err := RenderSite2CloudConfig(&buf, format, s2c, params)
+				assert.Nil(t, err)
+				golden := "testdata/golden/site2cloud-" + name + "." + ext
+				if *updateGolden {
+					if err := ioutil.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+						t.Fatal(err)
+					}
+				}
+				want, err := ioutil.ReadFile(golden)
+				if err != nil {
+					t.Fatal(err)
+				}
+				assert.Equal(t, string(want), buf.String())
+			})
+		}
+	}
+}
+
+func TestRenderSite2CloudConfigErrors(t *testing.T) {
+	s2c := &Site2Cloud{
+		TunnelName:   "onprem",
+		RemoteGwIP:   "198.51.100.7",
+		RemoteSubnet: "192.168.10.0/24",
+		LocalSubnet:  "10.10.0.0/16",
+	}
+	params := RemoteConfigParams{CloudGwIP: "52.0.0.10"}
+	var buf bytes.Buffer
+	assert.Nil(t, RenderSite2CloudConfig(&buf, CiscoIOS, s2c, params))
+	assert.Error(t, RenderSite2CloudConfig(&buf, "juniper", s2c, params))
+	assert.Error(t, RenderSite2CloudConfig(&buf, CiscoIOS, s2c, RemoteConfigParams{}))
+
+	gcm96 := *s2c
+	gcm96.Phase2Encryption = AES128GCM96
+	assert.Error(t, RenderSite2CloudConfig(&buf, CiscoIOS, &gcm96, params))
+	assert.Nil(t, RenderSite2CloudConfig(&buf, StrongSwanSwanctl, &gcm96, params))
+
+	ha := *s2c
+	ha.HAEnabled = "enabled"
+	assert.Error(t, RenderSite2CloudConfig(&buf, PfSenseXML, &ha, params))
+
+	custom := *s2c
+	custom.ConnType = Site2CloudMapped
+	custom.CustomMapped = "true"
+	assert.Error(t, RenderSite2CloudConfig(&buf, StrongSwanIPsecConf, &custom, params))
+
+	for _, psk := range []string{`key"`, "key\nconn evil", `key\`} {
+		quoted := *s2c
+		quoted.PreSharedKey = psk
+		assert.Error(t, RenderSite2CloudConfig(&buf, StrongSwanSwanctl, &quoted, params), psk)
+	}
+	spaced := *s2c
+	spaced.PreSharedKey = "my secret key"
+	assert.Error(t, RenderSite2CloudConfig(&buf, CiscoIOS, &spaced, params))
+	assert.Nil(t, RenderSite2CloudConfig(&buf, StrongSwanSwanctl, &spaced, params))
+	spaced.PreSharedKey = "key"
+	spaced.HAEnabled = "enabled"
+	spaced.BackupPreSharedKey = "backup key"
+	haParams := RemoteConfigParams{CloudGwIP: "52.0.0.10", BackupCloudGwIP: "52.0.0.11"}
+	assert.Error(t, RenderSite2CloudConfig(&buf, CiscoIOS, &spaced, haParams))
+
+	for _, name := range []string{"on prem", "onprem}", "onprem\n"} {
+		named := *s2c
+		named.TunnelName = name
+		assert.Error(t, RenderSite2CloudConfig(&buf, StrongSwanIPsecConf, &named, params), name)
+	}
+}
+
+func TestDownloadSite2CloudConfig(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "download_site2cloud_config":
+			if r.Form.Get("vendor") != "Cisco" || r.Form.Get("platform") != "ISR, ASR or CSR" {
+				w.Write([]byte(`{"return": false, "reason": "unknown vendor"}`))
+				return
+			}
+			w.Write([]byte(`{"return": true, "results": "crypto isakmp policy 1\n"}`))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	config, err := client.DownloadSite2CloudConfig(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"}, "Cisco",
+		"ISR, ASR or CSR", "IOS(XE)")
+	assert.Nil(t, err)
+	assert.Equal(t, "crypto isakmp policy 1\n", config)
+
+	_, err = client.DownloadSite2CloudConfig(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"}, "Acme", "", "")
+	assert.Error(t, err)
+}
@@ -0,0 +1,297 @@
+package goaviatrix
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Site2CloudPSKLength is the length of keys made by GenerateSite2CloudPSK.
+const Site2CloudPSKLength = 32
+
+const (
+	site2CloudPSKMinLength = 8
+	site2CloudPSKMaxLength = 64
+	site2CloudPSKAlphabet  = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789"
+	site2CloudPSKSymbols   = "._-+"
+)
+
+// GenerateSite2CloudPSK returns a random pre-shared key of
+// Site2CloudPSKLength letters and digits, read from crypto/rand. Keys start
+// with a letter so that strongSwan never mistakes them for its "0x" and "0s"
+// encoded forms.
+func GenerateSite2CloudPSK() (string, error) {
+	psk := make([]byte, Site2CloudPSKLength)
+	for i := range psk {
+		alphabet := site2CloudPSKAlphabet
+		if i == 0 {
+			alphabet = alphabet[:52]
+		}
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(alphabet))))
+		if err != nil {
+			return "", err
+		}
+		psk[i] = alphabet[n.Int64()]
+	}
+	return string(psk), nil
+}
+
+// ValidateSite2CloudPSK checks a pre-shared key against what the controller
+// and common remote devices accept: 8 to 64 characters, letters, digits and
+// "._-+" only, and no "0x" or "0s" prefix.
+func ValidateSite2CloudPSK(psk string) error {
+	if len(psk) < site2CloudPSKMinLength || len(psk) > site2CloudPSKMaxLength {
+		return fmt.Errorf("pre-shared key must be %d to %d characters long", site2CloudPSKMinLength,
+			site2CloudPSKMaxLength)
+	}
+	for _, r := range psk {
+		if !strings.ContainsRune(site2CloudPSKAlphabet+site2CloudPSKSymbols, r) {
+			return fmt.Errorf("pre-shared key contains invalid character %q", r)
+		}
+	}
+	if strings.HasPrefix(psk, "0x") || strings.HasPrefix(psk, "0s") {
+		return errors.New("pre-shared key must not start with 0x or 0s")
+	}
+	return nil
+}
+
+// UpdateSite2CloudPSK sets the pre-shared keys of a connection. An empty
+// PreSharedKey or BackupPreSharedKey leaves that key unchanged.
+func (c *Client) UpdateSite2CloudPSK(site2cloud *Site2Cloud) error {
+	if site2cloud.PreSharedKey == "" && site2cloud.BackupPreSharedKey == "" {
+		return errors.New("no pre-shared key given")
+	}
+	for _, psk := range []string{site2cloud.PreSharedKey, site2cloud.BackupPreSharedKey} {
+		if psk == "" {
+			continue
+		}
+		if err := ValidateSite2CloudPSK(psk); err != nil {
+			return err
+		}
+	}
+	verb := "POST"
+	body := fmt.Sprintf("CID=%s&action=update_site2cloud_pre_shared_key&vpc_id=%s&conn_name=%s", c.CID,
+		site2cloud.VpcID, site2cloud.TunnelName)
+	if site2cloud.PreSharedKey != "" {
+		body += "&pre_shared_key=" + url.QueryEscape(site2cloud.PreSharedKey)
+	}
+	if site2cloud.BackupPreSharedKey != "" {
+		body += "&backup_pre_shared_key=" + url.QueryEscape(site2cloud.BackupPreSharedKey)
+	}
+	log.Printf("[TRACE] %s %s updating pre-shared keys of %s", verb, c.baseURL, site2cloud.TunnelName)
+	req, err := http.NewRequest(verb, c.baseURL, strings.NewReader(body))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		return err
+	}
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	var data APIResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return err
+	}
+	if !data.Return {
+		return errors.New(data.Reason)
+	}
+	return nil
+}
+
+// PSKRotateOption is a functional option for RotateSite2CloudPSK.
+type PSKRotateOption func(*pskRotateConfig)
+
+type pskRotateConfig struct {
+	primary      bool
+	backup       bool
+	explicit     bool
+	remoteConfig io.Writer
+	format       RemoteConfigFormat
+	params       RemoteConfigParams
+	updateRemote func(*Site2Cloud) error
+	verify       bool
+	interval     time.Duration
+}
+
+// RotatePrimaryPSK limits a rotation to the primary tunnel's key.
+func RotatePrimaryPSK() PSKRotateOption {
+	return func(cfg *pskRotateConfig) {
+		cfg.primary, cfg.backup, cfg.explicit = true, false, true
+	}
+}
+
+// RotateBackupPSK limits a rotation to the backup tunnel's key.
+func RotateBackupPSK() PSKRotateOption {
+	return func(cfg *pskRotateConfig) {
+		cfg.primary, cfg.backup, cfg.explicit = false, true, true
+	}
+}
+
+// WithRemoteConfig makes the rotation render the remote device configuration
+// with the new keys to w once, after the last key change.
+func WithRemoteConfig(w io.Writer, format RemoteConfigFormat, params RemoteConfigParams) PSKRotateOption {
+	return func(cfg *pskRotateConfig) {
+		cfg.remoteConfig, cfg.format, cfg.params = w, format, params
+	}
+}
+
+// WithRemoteUpdate registers a function that pushes the new keys to the
+// remote device. It is called after each key change on the controller and
+// before the tunnel is verified.
+func WithRemoteUpdate(update func(*Site2Cloud) error) PSKRotateOption {
+	return func(cfg *pskRotateConfig) {
+		cfg.updateRemote = update
+	}
+}
+
+// VerifyTunnel makes the rotation wait, polling every interval, until the
+// tunnel whose key changed has come back up with it before moving on. A tunnel
+// still up on its old security association does not count: it must be seen
+// down after the change, or report a last change later than the change.
+// interval must be positive.
+func VerifyTunnel(interval time.Duration) PSKRotateOption {
+	return func(cfg *pskRotateConfig) {
+		cfg.verify, cfg.interval = true, interval
+	}
+}
+
+// PSKRotationResult holds the keys set by RotateSite2CloudPSK. A key that was
+// not rotated is empty. Status is the last tunnel status seen when the
+// rotation was verified.
+type PSKRotationResult struct {
+	PreSharedKey       string
+	BackupPreSharedKey string
+	Status             *Site2CloudStatus
+}
+
+// RotateSite2CloudPSK replaces the pre-shared keys of a connection with
+// generated ones. By default the primary key is rotated first and the backup
+// key, for HA connections, only once the primary tunnel is back, so one
+// tunnel carries traffic at all times; RotatePrimaryPSK and RotateBackupPSK
+// rotate a single key. After each key change the remote device is updated
+// when WithRemoteUpdate is given and the tunnel is waited for when
+// VerifyTunnel is given. With WithRemoteConfig the remote configuration is
+// written once, after the last key change. Rendering the remote config while
+// a key is kept needs that key in site2cloud. On error the result holds the
+// keys already set on the controller.
+func (c *Client) RotateSite2CloudPSK(ctx context.Context, site2cloud *Site2Cloud,
+	opts ...PSKRotateOption) (*PSKRotationResult, error) {
+	cfg := &pskRotateConfig{primary: true, backup: true}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if cfg.verify && cfg.interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %v", cfg.interval)
+	}
+
+	conn, err := c.GetSite2Cloud(site2cloud)
+	if err != nil {
+		return nil, err
+	}
+	ha := site2CloudHAEnabled(conn)
+	if cfg.backup && !ha {
+		if cfg.explicit {
+			return nil, fmt.Errorf("site2cloud connection %s has no backup tunnel", conn.TunnelName)
+		}
+		cfg.backup = false
+	}
+	// The controller does not report keys, so the rendered remote config can
+	// only hold a key that is not rotated if the caller passed it in.
+	conn.PreSharedKey = firstNonEmpty(conn.PreSharedKey, site2cloud.PreSharedKey)
+	conn.BackupPreSharedKey = firstNonEmpty(conn.BackupPreSharedKey, site2cloud.BackupPreSharedKey)
+	if cfg.remoteConfig != nil {
+		if !cfg.primary && conn.PreSharedKey == "" {
+			return nil, errors.New("the current primary pre-shared key is needed to render the remote config")
+		}
+		if ha && !cfg.backup && conn.BackupPreSharedKey == "" {
+			return nil, errors.New("the current backup pre-shared key is needed to render the remote config")
+		}
+	}
+
+	result := &PSKRotationResult{}
+	for _, backup := range []bool{false, true} {
+		if (!backup && !cfg.primary) || (backup && !cfg.backup) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		psk, err := GenerateSite2CloudPSK()
+		if err != nil {
+			return result, err
+		}
+		update := &Site2Cloud{VpcID: conn.VpcID, TunnelName: conn.TunnelName}
+		if backup {
+			update.BackupPreSharedKey = psk
+		} else {
+			update.PreSharedKey = psk
+		}
+		log.Printf("[INFO] Rotating %s pre-shared key of Site2Cloud connection %s", tunnelRole(backup),
+			conn.TunnelName)
+		rotated := time.Now()
+		if err := c.UpdateSite2CloudPSK(update); err != nil {
+			return result, err
+		}
+		if backup {
+			conn.BackupPreSharedKey = psk
+			result.BackupPreSharedKey = psk
+		} else {
+			conn.PreSharedKey = psk
+			result.PreSharedKey = psk
+		}
+
+		// the remote config is written once, holding every new key
+		if cfg.remoteConfig != nil && (backup || !cfg.backup) {
+			if err := RenderSite2CloudConfig(cfg.remoteConfig, cfg.format, conn, cfg.params); err != nil {
+				return result, err
+			}
+		}
+		if cfg.updateRemote != nil {
+			if err := cfg.updateRemote(conn); err != nil {
+				return result, fmt.Errorf("updating remote device: %v", err)
+			}
+		}
+		if cfg.verify {
+			if result.Status, err = c.waitForTunnelRekeyed(ctx, conn, backup, rotated, cfg.interval); err != nil {
+				return result, fmt.Errorf("%s tunnel did not come back up: %v", tunnelRole(backup), err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// waitForTunnelRekeyed polls the connection until the primary or, with
+// backup set, the backup tunnel is up on a key set at rotated: it has been
+// seen down since, or reports a later last change.
+func (c *Client) waitForTunnelRekeyed(ctx context.Context, site2cloud *Site2Cloud, backup bool,
+	rotated time.Time, interval time.Duration) (*Site2CloudStatus, error) {
+	wentDown := false
+	return c.waitForSite2Cloud(ctx, site2cloud, interval, func(status *Site2CloudStatus) bool {
+		tunnel := &status.Primary
+		if backup {
+			tunnel = status.Backup
+		}
+		if tunnel == nil || !tunnel.Up {
+			wentDown = true
+			return false
+		}
+		return wentDown || tunnel.LastChange.After(rotated)
+	})
+}
+
+func tunnelRole(backup bool) string {
+	if backup {
+		return "backup"
+	}
+	return "primary"
+}
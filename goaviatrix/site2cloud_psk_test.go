@@ -0,0 +1,133 @@
+package goaviatrix
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSite2CloudPSK(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i++ {
+		psk, err := GenerateSite2CloudPSK()
+		assert.Nil(t, err)
+		assert.Equal(t, Site2CloudPSKLength, len(psk))
+		assert.Nil(t, ValidateSite2CloudPSK(psk))
+		assert.False(t, seen[psk])
+		seen[psk] = true
+	}
+}
+
+func TestValidateSite2CloudPSK(t *testing.T) {
+	assert.Nil(t, ValidateSite2CloudPSK("abc.DEF-123+xyz_9"))
+	for _, psk := range []string{"short", strings.Repeat("a", 65), "has space in it", "amp&ersand", "0x12345678"} {
+		assert.Error(t, ValidateSite2CloudPSK(psk), psk)
+	}
+}
+
+func TestRotateSite2CloudPSK(t *testing.T) {
+	steps := make([]string, 0)
+	keys := make(map[string]string)
+	// a tunnel whose key changed is reported down once before coming back up
+	rekeying := make(map[string]bool)
+	backupLastChange := ""
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_site2cloud_conn":
+			w.Write([]byte(`{"return": true, "results": {"connections": [
+				{"vpc_id": "vpc-1", "name": "s2c-1", "peer_ip": "198.51.100.7", "gw_name": "gw1",
+				 "ha_status": "enabled", "remote_cidr": "192.168.0.0/24", "local_cidr": "10.0.0.0/16"}]}}`))
+		case "get_site2cloud_conn_detail":
+			w.Write([]byte(`{"return": true, "results": {"connections": {}}}`))
+		case "update_site2cloud_pre_shared_key":
+			if psk := r.PostForm.Get("pre_shared_key"); psk != "" {
+				keys["primary"] = psk
+				rekeying["primary"] = backupLastChange == ""
+				steps = append(steps, "update primary")
+			}
+			if psk := r.PostForm.Get("backup_pre_shared_key"); psk != "" {
+				keys["backup"] = psk
+				rekeying["backup"] = backupLastChange == ""
+				steps = append(steps, "update backup")
+			}
+			w.Write([]byte(apiSuccess))
+		case "get_site2cloud_tunnel_status":
+			steps = append(steps, "status")
+			state := map[string]string{"primary": "Up", "backup": "Up"}
+			for role := range rekeying {
+				if rekeying[role] {
+					state[role] = "Down"
+					rekeying[role] = false
+				}
+			}
+			w.Write([]byte(`{"return": true, "results": {"tunnels": [
+				{"gw_name": "gw1", "role": "primary", "status": "` + state["primary"] + `"},
+				{"gw_name": "gw1-hagw", "role": "backup", "status": "` + state["backup"] + `",
+				 "last_change": "` + backupLastChange + `"}]}}`))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	var config bytes.Buffer
+	_, err = client.RotateSite2CloudPSK(context.Background(), &Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		RotatePrimaryPSK(), WithRemoteConfig(&config, StrongSwanIPsecConf, RemoteConfigParams{CloudGwIP: "52.0.0.10"}))
+	assert.Error(t, err)
+	_, err = client.RotateSite2CloudPSK(context.Background(), &Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		VerifyTunnel(0))
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(steps))
+
+	result, err := client.RotateSite2CloudPSK(context.Background(),
+		&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		WithRemoteConfig(&config, StrongSwanIPsecConf, RemoteConfigParams{CloudGwIP: "52.0.0.10", BackupCloudGwIP: "52.0.0.11"}),
+		WithRemoteUpdate(func(s2c *Site2Cloud) error {
+			steps = append(steps, "remote")
+			return nil
+		}),
+		VerifyTunnel(time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update primary", "remote", "status", "status", "update backup", "remote", "status",
+		"status"}, steps)
+	assert.Equal(t, keys["primary"], result.PreSharedKey)
+	assert.Equal(t, keys["backup"], result.BackupPreSharedKey)
+	assert.Equal(t, 1, strings.Count(config.String(), "conn %default"))
+	assert.True(t, strings.Contains(config.String(), `PSK "`+result.PreSharedKey+`"`))
+	assert.True(t, strings.Contains(config.String(), `PSK "`+result.BackupPreSharedKey+`"`))
+
+	// a tunnel that never went down is accepted once it reports a later change
+	steps = steps[:0]
+	backupLastChange = time.Now().Add(time.Hour).UTC().Format(time.RFC3339)
+	_, err = client.RotateSite2CloudPSK(context.Background(), &Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		RotateBackupPSK(), VerifyTunnel(time.Millisecond))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update backup", "status"}, steps)
+
+	// one still up on its old key is not
+	steps = steps[:0]
+	backupLastChange = "2019-03-01T09:00:00Z"
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err = client.RotateSite2CloudPSK(ctx, &Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		RotateBackupPSK(), VerifyTunnel(time.Millisecond))
+	assert.Error(t, err)
+	backupLastChange = ""
+
+	steps = steps[:0]
+	result, err = client.RotateSite2CloudPSK(context.Background(), &Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"},
+		RotateBackupPSK())
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"update backup"}, steps)
+	assert.Equal(t, "", result.PreSharedKey)
+}
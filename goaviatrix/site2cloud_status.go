@@ -0,0 +1,201 @@
+package goaviatrix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TunnelStatus is the state of one IPsec tunnel of a Site2Cloud connection.
+// LastChange is zero when the controller does not report it.
+type TunnelStatus struct {
+	GwName     string
+	PeerIP     string
+	Up         bool
+	Status     string
+	LastChange time.Time
+	BytesIn    uint64
+	BytesOut   uint64
+}
+
+// Site2CloudStatus is the tunnel state of a Site2Cloud connection. Backup is
+// nil for connections without HA.
+type Site2CloudStatus struct {
+	TunnelName string
+	VpcID      string
+	Primary    TunnelStatus
+	Backup     *TunnelStatus
+}
+
+// Up reports whether traffic can flow over the connection, that is whether
+// the primary or the backup tunnel is up.
+func (s *Site2CloudStatus) Up() bool {
+	return s.Primary.Up || (s.Backup != nil && s.Backup.Up)
+}
+
+func (s *Site2CloudStatus) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s: primary %s", s.TunnelName, s.Primary.Status)
+	if s.Backup != nil {
+		fmt.Fprintf(&b, ", backup %s", s.Backup.Status)
+	}
+	return b.String()
+}
+
+type site2CloudStatusResp struct {
+	Return  bool                   `json:"return"`
+	Results site2CloudStatusResult `json:"results"`
+	Reason  string                 `json:"reason"`
+}
+
+type site2CloudStatusResult struct {
+	Status  string                   `json:"status"`
+	Tunnels []site2CloudTunnelStatus `json:"tunnels"`
+}
+
+type site2CloudTunnelStatus struct {
+	GwName     string      `json:"gw_name"`
+	PeerIP     string      `json:"peer_ip"`
+	Role       string      `json:"role"`
+	Status     string      `json:"status"`
+	LastChange interface{} `json:"last_change"`
+	RxBytes    json.Number `json:"rx_bytes"`
+	TxBytes    json.Number `json:"tx_bytes"`
+}
+
+// GetSite2CloudStatus returns the tunnel state of the connection named
+// site2cloud.TunnelName in site2cloud.VpcID. ErrNotFound is returned if the
+// controller does not know the connection.
+func (c *Client) GetSite2CloudStatus(site2cloud *Site2Cloud) (*Site2CloudStatus, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=get_site2cloud_tunnel_status&vpc_id=%s&conn_name=%s", c.CID,
+		site2cloud.VpcID, site2cloud.TunnelName)
+	resp, err := c.Get(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	var data site2CloudStatusResp
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, err
+	}
+	if !data.Return {
+		if strings.Contains(data.Reason, "does not exist") || strings.Contains(data.Reason, "not found") {
+			return nil, ErrNotFound
+		}
+		return nil, errors.New(data.Reason)
+	}
+
+	status := &Site2CloudStatus{
+		TunnelName: site2cloud.TunnelName,
+		VpcID:      site2cloud.VpcID,
+	}
+	tunnels := make([]TunnelStatus, 0, len(data.Results.Tunnels))
+	primary := -1
+	for i, entry := range data.Results.Tunnels {
+		tunnel, err := newTunnelStatus(entry)
+		if err != nil {
+			return nil, fmt.Errorf("tunnel %d: %v", i, err)
+		}
+		tunnels = append(tunnels, *tunnel)
+		if primary < 0 && (strings.EqualFold(entry.Role, "primary") ||
+			(site2cloud.GwName != "" && entry.GwName == site2cloud.GwName)) {
+			primary = i
+		}
+	}
+	if len(tunnels) == 0 {
+		// connections without per-tunnel details only report the overall state
+		status.Primary = TunnelStatus{Status: data.Results.Status, Up: strings.EqualFold(data.Results.Status, "up")}
+		return status, nil
+	}
+	if primary < 0 {
+		primary = 0
+	}
+	status.Primary = tunnels[primary]
+	for i := range tunnels {
+		if i != primary {
+			status.Backup = &tunnels[i]
+			break
+		}
+	}
+	return status, nil
+}
+
+func newTunnelStatus(entry site2CloudTunnelStatus) (*TunnelStatus, error) {
+	lastChange, err := parseTimestamp(entry.LastChange)
+	if err != nil {
+		return nil, err
+	}
+	bytesIn, err := parseByteCounter(entry.RxBytes)
+	if err != nil {
+		return nil, err
+	}
+	bytesOut, err := parseByteCounter(entry.TxBytes)
+	if err != nil {
+		return nil, err
+	}
+	return &TunnelStatus{
+		GwName:     entry.GwName,
+		PeerIP:     entry.PeerIP,
+		Up:         strings.EqualFold(entry.Status, "up"),
+		Status:     entry.Status,
+		LastChange: lastChange,
+		BytesIn:    bytesIn,
+		BytesOut:   bytesOut,
+	}, nil
+}
+
+func parseByteCounter(n json.Number) (uint64, error) {
+	if n == "" {
+		return 0, nil
+	}
+	v, err := strconv.ParseUint(n.String(), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid byte counter %q", n)
+	}
+	return v, nil
+}
+
+// WaitForSite2CloudUp polls the connection's status every interval until a
+// tunnel is up, and returns that status. A connection the controller does
+// not know yet is polled like a down one, since it may still be being
+// created. When ctx is done, the last status seen is returned with ctx's
+// error. interval must be positive.
+func (c *Client) WaitForSite2CloudUp(ctx context.Context, site2cloud *Site2Cloud,
+	interval time.Duration) (*Site2CloudStatus, error) {
+	return c.waitForSite2Cloud(ctx, site2cloud, interval, (*Site2CloudStatus).Up)
+}
+
+// waitForSite2Cloud polls the connection's status every interval until done
+// returns true for it, as described for WaitForSite2CloudUp.
+func (c *Client) waitForSite2Cloud(ctx context.Context, site2cloud *Site2Cloud, interval time.Duration,
+	done func(*Site2CloudStatus) bool) (*Site2CloudStatus, error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %v", interval)
+	}
+	var last *Site2CloudStatus
+	for {
+		status, err := c.GetSite2CloudStatus(site2cloud)
+		if err != nil && err != ErrNotFound {
+			return last, err
+		}
+		if status != nil {
+			last = status
+			if done(status) {
+				return status, nil
+			}
+			log.Printf("[INFO] Waiting for Site2Cloud connection %s", status)
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return last, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package goaviatrix
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const site2CloudTunnelStatusResp = `{
+	"return": true,
+	"results": {
+		"status": "Up",
+		"tunnels": [
+			{"gw_name": "gw1-hagw", "peer_ip": "198.51.100.8", "role": "backup", "status": "Down",
+			 "last_change": 1546300800, "rx_bytes": "0", "tx_bytes": 0},
+			{"gw_name": "gw1", "peer_ip": "198.51.100.7", "role": "primary", "status": "Up",
+			 "last_change": "2019-01-01 12:00:00", "rx_bytes": "123456", "tx_bytes": 654321}
+		]
+	}
+  }`
+
+func TestGetSite2CloudStatus(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "get_site2cloud_tunnel_status":
+			if r.Form.Get("conn_name") != "s2c-1" {
+				w.Write([]byte(`{"return": false, "reason": "Connection does not exist"}`))
+				return
+			}
+			w.Write([]byte(site2CloudTunnelStatusResp))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	status, err := client.GetSite2CloudStatus(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, true, status.Up())
+	assert.Equal(t, "gw1", status.Primary.GwName)
+	assert.Equal(t, true, status.Primary.Up)
+	assert.Equal(t, uint64(123456), status.Primary.BytesIn)
+	assert.Equal(t, uint64(654321), status.Primary.BytesOut)
+	assert.Equal(t, time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC), status.Primary.LastChange)
+	assert.NotNil(t, status.Backup)
+	assert.Equal(t, false, status.Backup.Up)
+	assert.Equal(t, time.Unix(1546300800, 0).UTC(), status.Backup.LastChange)
+	assert.Equal(t, "s2c-1: primary Up, backup Down", status.String())
+
+	_, err = client.GetSite2CloudStatus(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-2"})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestWaitForSite2CloudUp(t *testing.T) {
+	polls := 0
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "get_site2cloud_tunnel_status":
+			polls++
+			switch {
+			case polls == 1:
+				w.Write([]byte(`{"return": false, "reason": "Connection does not exist"}`))
+			case polls == 2 || r.Form.Get("conn_name") == "down":
+				w.Write([]byte(`{"return": true, "results": {"status": "Down"}}`))
+			default:
+				w.Write([]byte(`{"return": true, "results": {"status": "Up"}}`))
+			}
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	status, err := client.WaitForSite2CloudUp(context.Background(), &Site2Cloud{TunnelName: "s2c-1"},
+		time.Millisecond)
+	assert.Nil(t, err)
+	assert.Equal(t, true, status.Up())
+	assert.Equal(t, 3, polls)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	status, err = client.WaitForSite2CloudUp(ctx, &Site2Cloud{TunnelName: "down"}, time.Millisecond)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.Equal(t, false, status.Up())
+
+	_, err = client.WaitForSite2CloudUp(context.Background(), &Site2Cloud{TunnelName: "s2c-1"}, 0)
+	assert.Error(t, err)
+}
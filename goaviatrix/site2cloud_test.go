@@ -0,0 +1,242 @@
+package goaviatrix
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listSite2CloudConn = `{
+		"return": true,
+		"results": {
+			"connections": [
+				{"vpc_id": "vpc-other", "name": "s2c-1", "peer_ip": "203.0.113.9"},
+				{"vpc_id": "vpc-1", "name": "s2c-1", "peer_ip": "198.51.100.7", "connection_type": "mapped",
+				 "tunnel_type": "udp", "gw_name": "gw1", "remote_cidr": "192.168.0.0/24", "local_cidr": "10.0.0.0/16"},
+				{"vpc_id": "vpc-1", "name": "s2c-2", "peer_ip": "198.51.100.9", "tunnel_type": "tcp",
+				 "gw_name": "gw1", "ha_status": "enabled", "backup_gw_name": "gw1-hagw",
+				 "backup_peer_ip": "198.51.100.10"}
+			]
+		}
+	  }`
+	site2CloudConnDetail = `{
+		"return": true,
+		"results": {
+			"connections": {
+				"ike_version": "ikev2",
+				"ph1_encryption": "AES-256-CBC",
+				"ph1_authentication": "SHA-256",
+				"ph1_dh_group": "14",
+				"ph2_encryption": "AES-256-GCM-128",
+				"ph2_authentication": "NO-AUTH",
+				"ph2_dh_group": "14",
+				"local_cidr_virtual": "172.16.0.0/16",
+				"remote_cidr_virtual": "172.17.0.0/24"
+			}
+		}
+	  }`
+)
+
+func TestSite2CloudValidate(t *testing.T) {
+	valid := &Site2Cloud{
+		ConnType:                Site2CloudMapped,
+		IKEVersion:              IKEv2,
+		Phase1Encryption:        AES256CBC,
+		Phase1Auth:              SHA256,
+		Phase1DHGroup:           DHGroup14,
+		Phase2Encryption:        AES128GCM128,
+		Phase2Auth:              NoAuth,
+		Phase2DHGroup:           DHGroup19,
+		CustomMapped:            "true",
+		LocalSourceRealCIDRs:    "10.0.0.0/24,10.0.1.0/24",
+		LocalSourceVirtualCIDRs: "172.16.0.0/24,172.16.1.0/24",
+	}
+	assert.Nil(t, valid.Validate())
+	assert.Nil(t, (&Site2Cloud{}).Validate())
+	assert.Nil(t, (&Site2Cloud{LocalSubnetVirtual: "172.16.0.0/16"}).Validate())
+
+	tests := []struct {
+		name string
+		s2c  Site2Cloud
+	}{
+		{"ike version", Site2Cloud{IKEVersion: "ikev3"}},
+		{"unknown cipher", Site2Cloud{Phase2Encryption: "AES-512"}},
+		{"gcm in phase 1", Site2Cloud{Phase1Encryption: AES256GCM128}},
+		{"hmac in phase 1", Site2Cloud{Phase1Auth: HMACSHA256}},
+		{"sha in phase 2", Site2Cloud{Phase2Auth: SHA256}},
+		{"gcm with hmac", Site2Cloud{Phase2Encryption: AES256GCM96, Phase2Auth: HMACSHA1}},
+		{"dh group", Site2Cloud{Phase1DHGroup: "3"}},
+		{"conn type", Site2Cloud{ConnType: "bridged"}},
+		{"unmapped with mapping", Site2Cloud{ConnType: Site2CloudUnmapped, LocalSubnetVirtual: "172.16.0.0/16"}},
+		{"mapped length", Site2Cloud{ConnType: Site2CloudMapped, CustomMapped: "true",
+			RemoteSourceRealCIDRs: "192.168.0.0/24,192.168.1.0/24", RemoteSourceVirtualCIDRs: "172.17.0.0/24"}},
+		{"mapped cidr", Site2Cloud{ConnType: Site2CloudMapped, CustomMapped: "true",
+			RemoteSourceRealCIDRs: "192.168.0.0/33", RemoteSourceVirtualCIDRs: "172.17.0.0/24"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Error(t, tt.s2c.Validate())
+		})
+	}
+}
+
+func TestGetSite2Cloud(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_site2cloud_conn":
+			w.Write([]byte(listSite2CloudConn))
+		case "get_site2cloud_conn_detail":
+			switch {
+			case r.Form.Get("conn_name") == "s2c-2":
+				w.Write([]byte(`{"return": false, "reason": "Unknown action: get_site2cloud_conn_detail"}`))
+			case r.Form.Get("vpc_id") != "vpc-1" || r.Form.Get("conn_name") != "s2c-1":
+				w.Write([]byte(`{"return": false, "reason": "connection not found"}`))
+			default:
+				w.Write([]byte(site2CloudConnDetail))
+			}
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	if err != nil {
+		fmt.Println("unable to create client")
+	}
+	assert.Nil(t, err)
+
+	s2c, err := client.GetSite2Cloud(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1"})
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.7", s2c.RemoteGwIP)
+	assert.Equal(t, Site2CloudMapped, s2c.ConnType)
+	assert.Equal(t, IKEv2, s2c.IKEVersion)
+	assert.Equal(t, AES256CBC, s2c.Phase1Encryption)
+	assert.Equal(t, SHA256, s2c.Phase1Auth)
+	assert.Equal(t, DHGroup14, s2c.Phase1DHGroup)
+	assert.Equal(t, AES256GCM128, s2c.Phase2Encryption)
+	assert.Equal(t, NoAuth, s2c.Phase2Auth)
+	assert.Equal(t, "172.16.0.0/16", s2c.LocalSubnetVirtual)
+	assert.Equal(t, "172.17.0.0/24", s2c.RemoteSubnetVirtual)
+
+	// a controller without the detail call leaves the IPsec parameters empty
+	s2c, err = client.GetSite2Cloud(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "198.51.100.9", s2c.RemoteGwIP)
+	assert.Equal(t, IKEVersion(""), s2c.IKEVersion)
+
+	// any other failure of the detail call is returned
+	_, err = client.GetSite2Cloud(&Site2Cloud{VpcID: "vpc-other", TunnelName: "s2c-1"})
+	if assert.Error(t, err) {
+		assert.Equal(t, "connection not found", err.Error())
+	}
+
+	_, err = client.GetSite2Cloud(&Site2Cloud{VpcID: "vpc-2", TunnelName: "s2c-1"})
+	assert.Equal(t, ErrNotFound, err)
+}
+
+func TestUpdateSite2Cloud(t *testing.T) {
+	var form map[string]string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "edit_site2cloud_conn":
+			form = make(map[string]string)
+			for key := range r.PostForm {
+				form[key] = r.PostForm.Get(key)
+			}
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	err = client.UpdateSite2Cloud(&Site2Cloud{
+		VpcID:            "vpc-1",
+		TunnelName:       "s2c-1",
+		LocalSubnet:      "10.0.0.0/16",
+		RemoteSubnet:     "192.168.0.0/24",
+		Phase2Encryption: AES256CBC,
+		Phase2Auth:       HMACSHA256,
+		Phase2DHGroup:    DHGroup14,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, "s2c-1", form["conn_name"])
+	assert.Equal(t, "AES-256-CBC", form["phase2_encryption"])
+	assert.Equal(t, "HMAC-SHA-256", form["phase2_auth"])
+	assert.Equal(t, "14", form["phase2_dh_group"])
+	_, sent := form["phase1_encryption"]
+	assert.Equal(t, false, sent)
+
+	err = client.UpdateSite2Cloud(&Site2Cloud{VpcID: "vpc-1", TunnelName: "s2c-1", Phase1DHGroup: "7"})
+	assert.Error(t, err)
+}
+
+func TestListSite2Cloud(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_site2cloud_conn":
+			w.Write([]byte(listSite2CloudConn))
+		case "get_site2cloud_conn_detail":
+			w.Write([]byte(site2CloudConnDetail))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	conns, err := client.ListSite2Cloud(nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(conns))
+
+	conns, err = client.ListSite2Cloud(&Site2CloudFilter{GwName: "gw1", TunnelType: "TCP", HAEnabled: "enabled"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conns))
+	assert.Equal(t, "s2c-2", conns[0].TunnelName)
+
+	conns, err = client.ListSite2Cloud(&Site2CloudFilter{GwName: "gw1-hagw"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(conns)) {
+		assert.Equal(t, "s2c-2", conns[0].TunnelName)
+	}
+
+	conns, err = client.ListSite2Cloud(&Site2CloudFilter{RemoteGwIP: "198.51.100.10"})
+	assert.Nil(t, err)
+	if assert.Equal(t, 1, len(conns)) {
+		assert.Equal(t, "s2c-2", conns[0].TunnelName)
+	}
+
+	conns, err = client.ListSite2Cloud(&Site2CloudFilter{VpcID: "vpc-1", RemoteGwIP: "198.51.100.7"})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(conns))
+	assert.Equal(t, "s2c-1", conns[0].TunnelName)
+
+	s2c, err := client.GetSite2Cloud(&Site2Cloud{TunnelName: "s2c-2"})
+	assert.Nil(t, err)
+	assert.Equal(t, "vpc-1", s2c.VpcID)
+	assert.Equal(t, IKEv2, s2c.IKEVersion)
+
+	_, err = client.FindSite2CloudByName("s2c-1")
+	ambiguous, ok := err.(*Site2CloudAmbiguousError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"vpc-1", "vpc-other"}, ambiguous.VpcIDs)
+	assert.Equal(t, "site2cloud connection s2c-1 exists in several VPCs: vpc-1, vpc-other", err.Error())
+
+	_, err = client.FindSite2CloudByName("s2c-3")
+	assert.Equal(t, ErrNotFound, err)
+}
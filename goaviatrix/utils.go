@@ -2,6 +2,9 @@ package goaviatrix
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 )
 
 var ErrNotFound = fmt.Errorf("ErrNotFound")
@@ -68,3 +71,47 @@ func DifferenceSlice(a, b [][]string) [][]string {
 	}
 	return ab
 }
+
+var timestampLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"Jan 2 15:04:05 2006",
+}
+
+// parseTimestamp accepts a Unix timestamp (number or string) or one of
+// the textual layouts the controller is known to use. Textual times without a
+// zone are taken as UTC.
+func parseTimestamp(v interface{}) (time.Time, error) {
+	switch ts := v.(type) {
+	case nil:
+		return time.Time{}, nil
+	case float64:
+		return time.Unix(int64(ts), 0).UTC(), nil
+	case string:
+		ts = strings.TrimSpace(ts)
+		if ts == "" {
+			return time.Time{}, nil
+		}
+		if n, err := strconv.ParseInt(ts, 10, 64); err == nil {
+			return time.Unix(n, 0).UTC(), nil
+		}
+		for _, layout := range timestampLayouts {
+			if t, err := time.Parse(layout, ts); err == nil {
+				return t.UTC(), nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %v", v)
+}
+
+// firstNonEmpty returns the first of values that is not empty.
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"log"
+	"net/url"
 )
 
 // VPNUser simple struct to hold vpn_user details
@@ -26,7 +27,7 @@ type VPNUserListResp struct {
 
 func (c *Client) CreateVPNUser(vpn_user *VPNUser) error {
 	vpn_user.Action = "add_vpn_user"
-	path := c.baseURL + fmt.Sprintf("?CID=%s&action=%s&vpc_id=%s&username=%s&user_email=%s&lb_name=%s&saml_endpoint=%s", c.CID, vpn_user.Action, vpn_user.VpcID, vpn_user.UserName, vpn_user.UserEmail, vpn_user.GwName, vpn_user.SamlEndpoint)
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=%s&vpc_id=%s&username=%s&user_email=%s&lb_name=%s&saml_endpoint=%s", c.CID, vpn_user.Action, vpn_user.VpcID, url.QueryEscape(vpn_user.UserName), url.QueryEscape(vpn_user.UserEmail), vpn_user.GwName, url.QueryEscape(vpn_user.SamlEndpoint))
 
 	resp, err := c.Get(path, nil)
 	if err != nil {
@@ -42,9 +43,9 @@ func (c *Client) CreateVPNUser(vpn_user *VPNUser) error {
 	return nil
 }
 
-func (c *Client) GetVPNUser(vpn_user *VPNUser) (*VPNUser, error) {
-	vpn_user.Action = "list_vpn_users"
-	path := c.baseURL + fmt.Sprintf("?CID=%s&action=%s", c.CID, vpn_user.Action)
+// ListVPNUsers returns every VPN user known to the controller.
+func (c *Client) ListVPNUsers() ([]VPNUser, error) {
+	path := c.baseURL + fmt.Sprintf("?CID=%s&action=list_vpn_users", c.CID)
 	resp, err := c.Get(path, nil)
 	if err != nil {
 		return nil, err
@@ -56,7 +57,15 @@ func (c *Client) GetVPNUser(vpn_user *VPNUser) (*VPNUser, error) {
 	if !data.Return {
 		return nil, errors.New(data.Reason)
 	}
-	vulist := data.Results
+	return data.Results, nil
+}
+
+func (c *Client) GetVPNUser(vpn_user *VPNUser) (*VPNUser, error) {
+	vpn_user.Action = "list_vpn_users"
+	vulist, err := c.ListVPNUsers()
+	if err != nil {
+		return nil, err
+	}
 	for i := range vulist {
 		if vulist[i].UserName == vpn_user.UserName {
 			return &vulist[i], nil
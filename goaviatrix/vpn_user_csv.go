@@ -0,0 +1,194 @@
+package goaviatrix
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// vpnUserImportConcurrency bounds the number of add_vpn_user calls that
+// ImportVPNUsers keeps in flight at once.
+const vpnUserImportConcurrency = 5
+
+// vpnUserCSVHeader is the column layout read by ImportVPNUsers and written by
+// ExportVPNUsers.
+var vpnUserCSVHeader = []string{"username", "email", "vpc_id", "lb_name", "profile"}
+
+// vpnUserProfileSep separates the profiles of a user in the profile column.
+const vpnUserProfileSep = ";"
+
+// VPNUserImportResult reports the outcome of importing a single CSV row with
+// ImportVPNUsers.
+type VPNUserImportResult struct {
+	Row      int // 1-based index among the data rows, excluding any header
+	User     VPNUser
+	Profiles []string
+	Err      error
+}
+
+// ImportVPNUsers reads VPN users from CSV and creates them on the controller.
+// Each record holds username, email, vpc_id, lb_name and profile, in that
+// order; an optional header row is skipped. Every row is validated before any
+// user is created, and rows that pass are applied concurrently. The profile
+// column holds the profiles to attach the new user to, separated by ";".
+//
+// The returned report holds one entry per data row in input order, and a row
+// with the wrong number of fields is reported with its error. The error is
+// only set when the CSV itself cannot be read. Rows not yet applied when ctx
+// is cancelled are reported with ctx.Err().
+func (c *Client) ImportVPNUsers(ctx context.Context, r io.Reader) ([]VPNUserImportResult, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = len(vpnUserCSVHeader)
+	reader.TrimLeadingSpace = true
+
+	results := make([]VPNUserImportResult, 0)
+	seen := make(map[string]int)
+	for first := true; ; first = false {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if perr, ok := err.(*csv.ParseError); ok && perr.Err == csv.ErrFieldCount {
+			results = append(results, VPNUserImportResult{Row: len(results) + 1,
+				Err: fmt.Errorf("expected %d fields, got %d", len(vpnUserCSVHeader), len(record))})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if first && strings.EqualFold(record[0], vpnUserCSVHeader[0]) {
+			continue
+		}
+
+		results = append(results, VPNUserImportResult{Row: len(results) + 1})
+		res := &results[len(results)-1]
+		res.User = VPNUser{
+			UserName:  record[0],
+			UserEmail: record[1],
+			VpcID:     record[2],
+			GwName:    record[3],
+		}
+		for _, name := range strings.Split(record[4], vpnUserProfileSep) {
+			if name = strings.TrimSpace(name); name != "" {
+				res.Profiles = append(res.Profiles, name)
+			}
+		}
+		if err := validateVPNUser(&res.User); err != nil {
+			res.Err = err
+			continue
+		}
+		if row, ok := seen[res.User.UserName]; ok {
+			res.Err = fmt.Errorf("duplicate username %q (first seen in row %d)", res.User.UserName, row)
+			continue
+		}
+		seen[res.User.UserName] = res.Row
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, vpnUserImportConcurrency)
+	for i := range results {
+		if results[i].Err != nil {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			results[i].Err = ctx.Err()
+			continue
+		case sem <- struct{}{}:
+		}
+		wg.Add(1)
+		go func(res *VPNUserImportResult) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			res.Err = c.importVPNUser(res)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+func (c *Client) importVPNUser(res *VPNUserImportResult) error {
+	user := res.User
+	if err := c.CreateVPNUser(&user); err != nil {
+		return err
+	}
+	for _, name := range res.Profiles {
+		profile := &Profile{
+			Name:     name,
+			UserList: []string{user.UserName},
+		}
+		if err := c.AttachUsers(profile); err != nil {
+			return fmt.Errorf("user created but not attached to profile %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// ExportVPNUsers writes every VPN user on the controller as CSV in the format
+// read by ImportVPNUsers, including a header row. Users attached to several
+// profiles are written once, with the profiles separated by ";".
+func (c *Client) ExportVPNUsers(w io.Writer) error {
+	users, err := c.ListVPNUsers()
+	if err != nil {
+		return err
+	}
+	members, err := c.ListProfileMembers()
+	if err != nil {
+		return err
+	}
+
+	profiles := make(map[string][]string)
+	for name, userList := range members {
+		for _, user := range userList {
+			profiles[user] = append(profiles[user], name)
+		}
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].UserName < users[j].UserName })
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(vpnUserCSVHeader); err != nil {
+		return err
+	}
+	for _, user := range users {
+		names := profiles[user.UserName]
+		sort.Strings(names)
+		record := []string{user.UserName, user.UserEmail, user.VpcID, user.GwName,
+			strings.Join(names, vpnUserProfileSep)}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func validateVPNUser(user *VPNUser) error {
+	if user.UserName == "" {
+		return errors.New("username is required")
+	}
+	if strings.ContainsAny(user.UserName, " \t&?=/") {
+		return fmt.Errorf("invalid username %q", user.UserName)
+	}
+	if user.VpcID == "" {
+		return errors.New("vpc_id is required")
+	}
+	if user.GwName == "" {
+		return errors.New("lb_name is required")
+	}
+	if user.UserEmail != "" {
+		// only a bare address is accepted, not "Name <addr>"
+		addr, err := mail.ParseAddress(user.UserEmail)
+		if err != nil || addr.Address != user.UserEmail {
+			return fmt.Errorf("invalid email %q", user.UserEmail)
+		}
+	}
+	return nil
+}
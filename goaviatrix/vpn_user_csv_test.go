@@ -0,0 +1,211 @@
+package goaviatrix
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	listVPNUsers = `{
+		"return": true,
+		"results": [
+			{"_id": "bob", "email": "bob@example.com", "vpc_id": "vpc-2", "lb_name": "elb-2"},
+			{"_id": "alice", "email": "alice@example.com", "vpc_id": "vpc-1", "lb_name": "elb-1"}
+		]
+	  }`
+	listProfileMembers = `{
+		"return": true,
+		"results": {"dev": ["alice"], "ops": ["alice"]}
+	  }`
+	apiSuccess = `{
+		"return": true,
+		"results": "success"
+	  }`
+)
+
+func TestImportVPNUsers(t *testing.T) {
+	var mu sync.Mutex
+	created := make(map[string]string)
+	attached := make(map[string]string)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "add_vpn_user":
+			assert.Equal(t, "57e098ed708a8", r.Form.Get("CID"))
+			created[r.Form.Get("username")] = r.Form.Get("vpc_id")
+			w.Write([]byte(apiSuccess))
+		case "add_profile_member":
+			attached[r.Form.Get("username")] = r.Form.Get("profile_name")
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	input := strings.Join([]string{
+		"username,email,vpc_id,lb_name,profile",
+		"alice,alice@example.com,vpc-1,elb-1,dev",
+		"bob,,vpc-2,elb-2,",
+		"carol,not-an-email,vpc-1,elb-1,",
+		"alice,alice@example.com,vpc-1,elb-1,",
+		",,vpc-1,elb-1,",
+		"erin,vpc-1",
+		"dave+ops,,vpc-1,elb-1,ops",
+	}, "\n")
+	results, err := client.ImportVPNUsers(context.Background(), strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, 7, len(results))
+	assert.Nil(t, results[0].Err)
+	assert.Nil(t, results[1].Err)
+	assert.NotNil(t, results[2].Err)
+	assert.NotNil(t, results[3].Err)
+	assert.NotNil(t, results[4].Err)
+	assert.Equal(t, 4, results[3].Row)
+	if assert.Error(t, results[5].Err) {
+		assert.Equal(t, "expected 5 fields, got 2", results[5].Err.Error())
+	}
+	assert.Equal(t, 6, results[5].Row)
+	assert.Nil(t, results[6].Err)
+
+	assert.Equal(t, map[string]string{"alice": "vpc-1", "bob": "vpc-2", "dave+ops": "vpc-1"}, created)
+	assert.Equal(t, map[string]string{"alice": "dev", "dave+ops": "ops"}, attached)
+}
+
+func TestImportVPNUsersBadCSV(t *testing.T) {
+	client := &Client{}
+	_, err := client.ImportVPNUsers(context.Background(), strings.NewReader("alice,\"vpc-1\n"))
+	assert.NotNil(t, err)
+}
+
+func TestExportVPNUsers(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_vpn_users":
+			w.Write([]byte(listVPNUsers))
+		case "list_user_profile_names":
+			w.Write([]byte(listProfileMembers))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	err = client.ExportVPNUsers(&buf)
+	assert.Nil(t, err)
+	assert.Equal(t, "username,email,vpc_id,lb_name,profile\n"+
+		"alice,alice@example.com,vpc-1,elb-1,dev;ops\n"+
+		"bob,bob@example.com,vpc-2,elb-2,\n", buf.String())
+}
+
+func TestExportImportVPNUsers(t *testing.T) {
+	var mu sync.Mutex
+	created := make(map[string]VPNUser)
+	attached := make(map[string][]string)
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_vpn_users":
+			w.Write([]byte(listVPNUsers))
+		case "list_user_profile_names":
+			w.Write([]byte(listProfileMembers))
+		case "add_vpn_user":
+			created[r.Form.Get("username")] = VPNUser{UserName: r.Form.Get("username"),
+				UserEmail: r.Form.Get("user_email"), VpcID: r.Form.Get("vpc_id"), GwName: r.Form.Get("lb_name")}
+			w.Write([]byte(apiSuccess))
+		case "add_profile_member":
+			attached[r.Form.Get("username")] = append(attached[r.Form.Get("username")], r.Form.Get("profile_name"))
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	var buf bytes.Buffer
+	assert.Nil(t, client.ExportVPNUsers(&buf))
+	results, err := client.ImportVPNUsers(context.Background(), &buf)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(results))
+	for _, res := range results {
+		assert.Nil(t, res.Err)
+	}
+	assert.Equal(t, map[string]VPNUser{
+		"alice": {UserName: "alice", UserEmail: "alice@example.com", VpcID: "vpc-1", GwName: "elb-1"},
+		"bob":   {UserName: "bob", UserEmail: "bob@example.com", VpcID: "vpc-2", GwName: "elb-2"},
+	}, created)
+	assert.Equal(t, map[string][]string{"alice": {"dev", "ops"}}, attached)
+}
+
+func TestImportVPNUsersEmail(t *testing.T) {
+	var email string
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "add_vpn_user":
+			email = r.Form.Get("user_email")
+			w.Write([]byte(apiSuccess))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	results, err := client.ImportVPNUsers(context.Background(), strings.NewReader(
+		"alice,a+b@example.com,vpc-1,elb-1,\n"+
+			"bob,Bob <bob@example.com>,vpc-1,elb-1,\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, results[0].Err)
+	assert.NotNil(t, results[1].Err)
+	assert.Equal(t, "a+b@example.com", email)
+}
+
+func TestListProfileMembersError(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		switch r.Form.Get("action") {
+		case "login":
+			w.Write([]byte(fixture("loginRespSuccess.json")))
+		case "list_user_profile_names":
+			w.Write([]byte(`{"return": false, "reason": "CID is invalid or expired."}`))
+		}
+	})
+	httpClient, teardown := testingHTTPClient(h)
+	defer teardown()
+
+	client, err := NewClient("testuser", "testing123!", "localhost", SetHTTPClient(httpClient), BaseURL(server.URL+"/v1/api"))
+	assert.Nil(t, err)
+
+	_, err = client.ListProfileMembers()
+	if assert.NotNil(t, err) {
+		assert.Equal(t, "CID is invalid or expired.", err.Error())
+	}
+}